@@ -0,0 +1,263 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/MukeshGKastala/nola-otel-demo/common/expr"
+)
+
+// Decimal evaluates +, -, *, / and parentheses over decimal numbers with
+// arbitrary-precision rational arithmetic, so results such as 0.1 + 0.2 are
+// exact. Of expr.Functions it supports only those with exact results: abs,
+// min, max, round and pow with an integer exponent.
+type Decimal struct {
+	// Precision is the number of decimal places a non-terminating result,
+	// such as 1/3, is rounded to. Defaults to 34.
+	Precision int
+}
+
+// Literals are limited to plain decimal notation with a small exponent, so a
+// literal such as 1e999999999 can't exhaust memory.
+var decimalLiteral = regexp.MustCompile(`^(\d+\.?\d*|\.\d+)([eE]([+-]?\d+))?$`)
+
+const (
+	maxDecimalExponent = 100
+	// maxDecimalBits bounds the size of a pow result.
+	maxDecimalBits = 1 << 16
+)
+
+func (d Decimal) Evaluate(expression string, variables map[string]float64) (Result, error) {
+	node, err := parser.ParseExpr(expression)
+	if err != nil {
+		return Result{}, fmt.Errorf("syntax error: %w", err)
+	}
+
+	r, err := d.eval(node, variables)
+	if err != nil {
+		return Result{}, err
+	}
+
+	precision := d.Precision
+	if precision <= 0 {
+		precision = 34
+	}
+
+	// Value is stored alongside the decimal, so it must fit a float64.
+	f, _ := r.Float64()
+	if math.IsInf(f, 0) {
+		return Result{}, errors.New("result is out of range")
+	}
+	return Result{Value: f, Decimal: formatDecimal(r, precision)}, nil
+}
+
+func (d Decimal) eval(node ast.Expr, variables map[string]float64) (*big.Rat, error) {
+	switch n := node.(type) {
+	case *ast.ParenExpr:
+		return d.eval(n.X, variables)
+	case *ast.BasicLit:
+		return parseDecimal(n)
+	case *ast.Ident:
+		v, ok := variables[n.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q", n.Name)
+		}
+		// The shortest representation that round-trips is the decimal
+		// the caller wrote.
+		r, _ := new(big.Rat).SetString(strconv.FormatFloat(v, 'g', -1, 64))
+		return r, nil
+	case *ast.CallExpr:
+		return d.call(n, variables)
+	case *ast.UnaryExpr:
+		x, err := d.eval(n.X, variables)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return x, nil
+		case token.SUB:
+			return x.Neg(x), nil
+		}
+		return nil, fmt.Errorf("unsupported operator %s", n.Op)
+	case *ast.BinaryExpr:
+		x, err := d.eval(n.X, variables)
+		if err != nil {
+			return nil, err
+		}
+		y, err := d.eval(n.Y, variables)
+		if err != nil {
+			return nil, err
+		}
+		switch n.Op {
+		case token.ADD:
+			return x.Add(x, y), nil
+		case token.SUB:
+			return x.Sub(x, y), nil
+		case token.MUL:
+			return x.Mul(x, y), nil
+		case token.QUO:
+			if y.Sign() == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return x.Quo(x, y), nil
+		}
+		return nil, fmt.Errorf("unsupported operator %s", n.Op)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", node)
+}
+
+func (d Decimal) call(n *ast.CallExpr, variables map[string]float64) (*big.Rat, error) {
+	fn, ok := n.Fun.(*ast.Ident)
+	if !ok || n.Ellipsis.IsValid() {
+		return nil, errors.New("unsupported call")
+	}
+
+	args := make([]*big.Rat, len(n.Args))
+	for i, arg := range n.Args {
+		r, err := d.eval(arg, variables)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = r
+	}
+
+	arity := func(minArgs, maxArgs int) error {
+		if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+			return fmt.Errorf("%s: wrong number of arguments", fn.Name)
+		}
+		return nil
+	}
+
+	switch fn.Name {
+	case "abs":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return args[0].Abs(args[0]), nil
+	case "min", "max":
+		if err := arity(1, -1); err != nil {
+			return nil, err
+		}
+		m := args[0]
+		for _, r := range args[1:] {
+			if c := r.Cmp(m); (fn.Name == "min" && c < 0) || (fn.Name == "max" && c > 0) {
+				m = r
+			}
+		}
+		return m, nil
+	case "round":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		return roundDecimal(args[0]), nil
+	case "pow":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		return powDecimal(args[0], args[1])
+	}
+
+	if _, ok := expr.Functions[fn.Name]; ok {
+		return nil, fmt.Errorf("%s is not supported in decimal mode", fn.Name)
+	}
+	return nil, fmt.Errorf("no such function %q", fn.Name)
+}
+
+// roundDecimal rounds half away from zero, as math.Round does.
+func roundDecimal(x *big.Rat) *big.Rat {
+	q, m := new(big.Int).QuoRem(new(big.Int).Abs(x.Num()), x.Denom(), new(big.Int))
+	if m.Lsh(m, 1).Cmp(x.Denom()) >= 0 {
+		q.Add(q, big.NewInt(1))
+	}
+	if x.Sign() < 0 {
+		q.Neg(q)
+	}
+	return new(big.Rat).SetInt(q)
+}
+
+func powDecimal(x, y *big.Rat) (*big.Rat, error) {
+	if !y.IsInt() || !y.Num().IsInt64() {
+		return nil, errors.New("pow: exponent must be an integer in decimal mode")
+	}
+
+	e := y.Num().Int64()
+	if e < 0 {
+		if x.Sign() == 0 {
+			return nil, errors.New("division by zero")
+		}
+		x.Inv(x)
+		e = -e
+	}
+
+	bits := max(x.Num().BitLen(), x.Denom().BitLen())
+	if e > maxDecimalBits || int64(bits)*e > maxDecimalBits {
+		return nil, errors.New("pow: result too large")
+	}
+
+	exp := big.NewInt(e)
+	num := new(big.Int).Exp(x.Num(), exp, nil)
+	denom := new(big.Int).Exp(x.Denom(), exp, nil)
+	return new(big.Rat).SetFrac(num, denom), nil
+}
+
+func parseDecimal(lit *ast.BasicLit) (*big.Rat, error) {
+	m := decimalLiteral.FindStringSubmatch(lit.Value)
+	if (lit.Kind != token.INT && lit.Kind != token.FLOAT) || m == nil {
+		return nil, fmt.Errorf("unsupported literal %s", lit.Value)
+	}
+	if m[3] != "" {
+		exp, err := strconv.Atoi(m[3])
+		if err != nil || exp > maxDecimalExponent || exp < -maxDecimalExponent {
+			return nil, fmt.Errorf("exponent of %s out of range", lit.Value)
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(lit.Value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported literal %s", lit.Value)
+	}
+	return r, nil
+}
+
+// formatDecimal writes r exactly if its decimal expansion terminates within
+// precision places, and rounded to precision places otherwise.
+func formatDecimal(r *big.Rat, precision int) string {
+	// A fraction in lowest terms terminates if its denominator has no prime
+	// factors other than 2 and 5. It then needs as many places as the larger
+	// of the two exponents.
+	denom := new(big.Int).Set(r.Denom())
+	var twos, fives int
+	for denom.Bit(0) == 0 {
+		denom.Rsh(denom, 1)
+		twos++
+	}
+	five, q, m := big.NewInt(5), new(big.Int), new(big.Int)
+	for {
+		q.QuoRem(denom, five, m)
+		if m.Sign() != 0 {
+			break
+		}
+		denom.Set(q)
+		fives++
+	}
+
+	places := max(twos, fives)
+	if denom.Cmp(big.NewInt(1)) != 0 || places > precision {
+		places = precision
+	}
+
+	s := r.FloatString(places)
+	if strings.Contains(s, ".") {
+		s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	}
+	return s
+}
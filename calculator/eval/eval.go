@@ -0,0 +1,218 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"math"
+	"time"
+
+	"github.com/MukeshGKastala/nola-otel-demo/common/expr"
+	"github.com/maja42/goval"
+)
+
+// Result is the value of an evaluated expression.
+type Result struct {
+	Value float64
+	// Decimal is the exact value in decimal notation. It is only set by
+	// evaluators that compute one.
+	Decimal string
+}
+
+// Evaluator evaluates a single expression. Expressions may use the given
+// variables and call the functions in expr.Functions.
+type Evaluator interface {
+	Evaluate(expression string, variables map[string]float64) (Result, error)
+}
+
+// Goval evaluates expressions with float64 arithmetic using goval.
+type Goval struct{}
+
+var govalFunctions = func() map[string]goval.ExpressionFunction {
+	fns := make(map[string]goval.ExpressionFunction, len(expr.Functions))
+	for name, fn := range expr.Functions {
+		fn := fn
+		// goval prefixes errors with the function name.
+		fns[name] = func(args ...interface{}) (interface{}, error) {
+			if len(args) < fn.MinArgs || (fn.MaxArgs >= 0 && len(args) > fn.MaxArgs) {
+				return nil, errors.New("wrong number of arguments")
+			}
+
+			nums := make([]float64, len(args))
+			for i, arg := range args {
+				switch n := arg.(type) {
+				case int:
+					nums[i] = float64(n)
+				case float64:
+					nums[i] = n
+				default:
+					return nil, fmt.Errorf("argument %d is not a number", i+1)
+				}
+			}
+
+			return fn.Call(nums...), nil
+		}
+	}
+	return fns
+}()
+
+func (Goval) Evaluate(expression string, variables map[string]float64) (Result, error) {
+	vars := make(map[string]interface{}, len(variables))
+	for name, v := range variables {
+		vars[name] = v
+	}
+
+	v, err := goval.NewEvaluator().Evaluate(expression, vars, govalFunctions)
+	if err != nil {
+		return Result{}, err
+	}
+
+	switch n := v.(type) {
+	case int:
+		return Result{Value: float64(n)}, nil
+	case float64:
+		// JSON, and so the result queue, can't carry NaN or infinities.
+		if math.IsNaN(n) || math.IsInf(n, 0) {
+			return Result{}, errors.New("result is not a finite number")
+		}
+		return Result{Value: n}, nil
+	default:
+		return Result{}, errors.New("result is not a number")
+	}
+}
+
+// ErrRejected is returned for expressions that exceed the Limits. Retrying a
+// rejected expression can't succeed.
+var ErrRejected = errors.New("expression rejected")
+
+type Limits struct {
+	// MaxLength is the maximum expression length in bytes. Defaults to 1024.
+	MaxLength int
+	// MaxDepth is the maximum nesting of parentheses, brackets and braces.
+	// Defaults to 32.
+	MaxDepth int
+	// MaxOps is the maximum number of operators, calls and index
+	// expressions. Defaults to 256.
+	MaxOps int
+	// Timeout bounds a single evaluation. Defaults to 1s.
+	Timeout time.Duration
+}
+
+// Sandbox evaluates untrusted expressions with an Evaluator within Limits.
+type Sandbox struct {
+	evaluator Evaluator
+	limits    Limits
+}
+
+func NewSandbox(evaluator Evaluator, limits Limits) *Sandbox {
+	if limits.MaxLength <= 0 {
+		limits.MaxLength = 1024
+	}
+	if limits.MaxDepth <= 0 {
+		limits.MaxDepth = 32
+	}
+	if limits.MaxOps <= 0 {
+		limits.MaxOps = 256
+	}
+	if limits.Timeout <= 0 {
+		limits.Timeout = time.Second
+	}
+
+	return &Sandbox{evaluator: evaluator, limits: limits}
+}
+
+// Evaluate checks expression against the limits and evaluates it. Errors
+// wrapping ErrRejected mean it broke a limit; any other error means it is
+// invalid.
+func (s *Sandbox) Evaluate(ctx context.Context, expression string, variables map[string]float64) (Result, error) {
+	if unknown := expr.UnknownIdentifiers(expression, variables); len(unknown) > 0 {
+		return Result{}, fmt.Errorf("unknown identifier %q", unknown[0])
+	}
+	if err := s.check(expression); err != nil {
+		return Result{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.limits.Timeout)
+	defer cancel()
+
+	type result struct {
+		r   Result
+		err error
+	}
+
+	// Evaluators can't be interrupted, so an evaluation that times out is
+	// abandoned rather than stopped. The length and operation limits bound
+	// how long it keeps running.
+	done := make(chan result, 1)
+	go func() {
+		// goval re-panics on runtime errors such as integer division by
+		// zero, which would otherwise take the whole process down.
+		defer func() {
+			if p := recover(); p != nil {
+				done <- result{err: fmt.Errorf("evaluation failed: %v", p)}
+			}
+		}()
+
+		r, err := s.evaluator.Evaluate(expression, variables)
+		done <- result{r, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return Result{}, fmt.Errorf("%w: evaluation exceeded %s", ErrRejected, s.limits.Timeout)
+		}
+		return Result{}, ctx.Err()
+	case r := <-done:
+		return r.r, r.err
+	}
+}
+
+// check scans expression with the same tokenizer goval and Decimal use.
+// Syntax errors are left for the evaluator to report.
+func (s *Sandbox) check(expression string) error {
+	if len(expression) > s.limits.MaxLength {
+		return fmt.Errorf("%w: length exceeds %d", ErrRejected, s.limits.MaxLength)
+	}
+
+	var sc scanner.Scanner
+	fset := token.NewFileSet()
+	sc.Init(fset.AddFile("", fset.Base(), len(expression)), []byte(expression), nil, 0)
+
+	var depth, ops int
+	prev := token.ILLEGAL
+	for {
+		_, tok, _ := sc.Scan()
+		if tok == token.EOF {
+			return nil
+		}
+
+		switch tok {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+			if depth > s.limits.MaxDepth {
+				return fmt.Errorf("%w: nesting depth exceeds %d", ErrRejected, s.limits.MaxDepth)
+			}
+			// Grouping parentheses are free; calls, indexing and
+			// literals are not.
+			if tok != token.LPAREN || prev == token.IDENT {
+				ops++
+			}
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		case token.COMMA, token.COLON, token.SEMICOLON:
+		default:
+			if tok.IsOperator() {
+				ops++
+			}
+		}
+
+		if ops > s.limits.MaxOps {
+			return fmt.Errorf("%w: operation count exceeds %d", ErrRejected, s.limits.MaxOps)
+		}
+
+		prev = tok
+	}
+}
@@ -0,0 +1,155 @@
+package eval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubEvaluator returns 1 once release is closed, or straight away if it is
+// nil.
+type stubEvaluator struct {
+	release chan struct{}
+}
+
+func (e stubEvaluator) Evaluate(string, map[string]float64) (Result, error) {
+	if e.release != nil {
+		<-e.release
+	}
+	return Result{Value: 1}, nil
+}
+
+func TestSandboxLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		limits       Limits
+		expression   string
+		block        bool
+		wantRejected bool
+	}{
+		{
+			name:       "length at the limit",
+			limits:     Limits{MaxLength: 5},
+			expression: "1+2+3",
+		},
+		{
+			name:         "length over the limit",
+			limits:       Limits{MaxLength: 5},
+			expression:   "1+2+34",
+			wantRejected: true,
+		},
+		{
+			name:       "nesting at the limit",
+			limits:     Limits{MaxDepth: 2},
+			expression: "((1))",
+		},
+		{
+			name:         "nesting over the limit",
+			limits:       Limits{MaxDepth: 2},
+			expression:   "(((1)))",
+			wantRejected: true,
+		},
+		{
+			name:       "operations at the limit",
+			limits:     Limits{MaxOps: 2},
+			expression: "1+2+3",
+		},
+		{
+			name:         "operations over the limit",
+			limits:       Limits{MaxOps: 2},
+			expression:   "1+2+3+4",
+			wantRejected: true,
+		},
+		{
+			name:       "grouping parentheses are free",
+			limits:     Limits{MaxOps: 1},
+			expression: "((1+2))",
+		},
+		{
+			name:         "calls are operations",
+			limits:       Limits{MaxOps: 1},
+			expression:   "abs(1)+2",
+			wantRejected: true,
+		},
+		{
+			name:       "within the timeout",
+			limits:     Limits{Timeout: time.Second},
+			expression: "1",
+		},
+		{
+			name:         "past the timeout",
+			limits:       Limits{Timeout: 10 * time.Millisecond},
+			expression:   "1",
+			block:        true,
+			wantRejected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var evaluator stubEvaluator
+			if tt.block {
+				evaluator.release = make(chan struct{})
+				t.Cleanup(func() { close(evaluator.release) })
+			}
+
+			_, err := NewSandbox(evaluator, tt.limits).Evaluate(context.Background(), tt.expression, nil)
+			if tt.wantRejected {
+				if !errors.Is(err, ErrRejected) {
+					t.Errorf("got %v, want ErrRejected", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("got %v, want no error", err)
+			}
+		})
+	}
+}
+
+func TestSandboxRecoversPanics(t *testing.T) {
+	sandbox := NewSandbox(Goval{}, Limits{})
+
+	for _, expression := range []string{"1/0", "1%0"} {
+		t.Run(expression, func(t *testing.T) {
+			_, err := sandbox.Evaluate(context.Background(), expression, nil)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if errors.Is(err, ErrRejected) {
+				t.Fatalf("expected an evaluation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestGovalRejectsNonFiniteResults(t *testing.T) {
+	for _, expression := range []string{"sqrt(-1)", "log(0)", "pow(10, 400)"} {
+		t.Run(expression, func(t *testing.T) {
+			if r, err := (Goval{}).Evaluate(expression, nil); err == nil {
+				t.Fatalf("expected an error, got %v", r.Value)
+			}
+		})
+	}
+}
+
+func TestGovalRejectsNonNumericResults(t *testing.T) {
+	for _, expression := range []string{"1 < 2", `"a"`, "[1, 2]"} {
+		t.Run(expression, func(t *testing.T) {
+			if _, err := (Goval{}).Evaluate(expression, nil); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestDecimalRejectsOverflow(t *testing.T) {
+	for _, expression := range []string{"pow(10, 400)", "1e100*1e100*1e100*1e100"} {
+		t.Run(expression, func(t *testing.T) {
+			if r, err := (Decimal{}).Evaluate(expression, nil); err == nil {
+				t.Fatalf("expected an error, got %s", r.Decimal)
+			}
+		})
+	}
+}
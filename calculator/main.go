@@ -3,115 +3,115 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path"
+	"runtime"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/MukeshGKastala/nola-otel-demo/calculator/eval"
 	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
+	"github.com/MukeshGKastala/nola-otel-demo/common/queue"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/google/uuid"
-	"github.com/maja42/goval"
-	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type problem struct {
-	ID         uuid.UUID `json:"id"`
-	Student    string    `json:"student"`
-	Expression string    `json:"expression"`
+	ID         uuid.UUID          `json:"id"`
+	Student    string             `json:"student"`
+	Expression string             `json:"expression"`
+	Variables  map[string]float64 `json:"variables,omitempty"`
+	Mode       string             `json:"mode"`
 }
 
+const (
+	modeFloat   = "float"
+	modeDecimal = "decimal"
+)
+
+const (
+	statusCompleted = "completed"
+	statusFailed    = "failed"
+)
+
 type solution struct {
-	ID     uuid.UUID `json:"id"`
-	Result float64   `json:"result"`
+	ID      uuid.UUID `json:"id"`
+	Status  string    `json:"status"`
+	Result  float64   `json:"result"`
+	Decimal string    `json:"decimal,omitempty"`
+	Error   string    `json:"error,omitempty"`
 }
 
 type calculator struct {
 	client        *sqs.Client
-	readQueueUrl  string
 	writeQueueUrl string
+	sandboxes     map[string]*eval.Sandbox
+	evalDuration  metric.Float64Histogram
 }
 
-func (c *calculator) process(ctx context.Context) error {
-	gMInput := &sqs.ReceiveMessageInput{
-		MessageAttributeNames: []string{"b3"},
-		QueueUrl:              aws.String(c.readQueueUrl),
-		VisibilityTimeout:     60,
-		WaitTimeSeconds:       10,
+func (c *calculator) solve(ctx context.Context, p problem) error {
+	if p.Student == "lazy" {
+		time.Sleep(15 * time.Millisecond)
 	}
 
-	for {
-		resp, err := c.client.ReceiveMessage(ctx, gMInput)
-		if err != nil {
-			return err
-		}
+	mode := p.Mode
+	if mode == "" {
+		mode = modeFloat
+	}
+
+	sandbox, ok := c.sandboxes[mode]
+	if !ok {
+		return c.enqueueSolution(ctx, solution{
+			ID:     p.ID,
+			Status: statusFailed,
+			Error:  fmt.Sprintf("unknown mode %q", p.Mode),
+		})
+	}
 
-		for _, msg := range resp.Messages {
-			ctx := otelsqs.NewCarrier().Extract(msg.MessageAttributes)
-			opts := []trace.SpanStartOption{
-				trace.WithSpanKind(trace.SpanKindConsumer),
-				trace.WithAttributes(
-					semconv.MessagingSystemKey.String("elasticmq"),
-					semconv.MessagingDestinationName(path.Base(c.readQueueUrl)),
-					semconv.MessagingMessageID(*msg.MessageId),
-				),
-			}
-			ctx, span := otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s process", path.Base(c.readQueueUrl)), opts...)
-
-			var p problem
-			if err := json.Unmarshal([]byte(*msg.Body), &p); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			if p.Student == "lazy" {
-				time.Sleep(15 * time.Millisecond)
-			}
-
-			v, err := goval.NewEvaluator().Evaluate(p.Expression, nil, nil)
-			if err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			var result float64
-			if n, ok := v.(int); ok {
-				result = float64(n)
-			} else if f, ok := v.(float64); ok {
-				result = f
-			}
-
-			s := solution{p.ID, result}
-			if err := c.enqueueSolution(ctx, s); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			if _, err := c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-				QueueUrl:      aws.String(c.readQueueUrl),
-				ReceiptHandle: msg.ReceiptHandle,
-			}); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			span.End()
+	start := time.Now()
+	result, err := sandbox.Evaluate(ctx, p.Expression, p.Variables)
+	attrs := []attribute.KeyValue{
+		attribute.Bool("error", err != nil),
+		attribute.String("mode", mode),
+	}
+	if err != nil {
+		errorType := semconv.ErrorTypeKey.String("invalid")
+		if errors.Is(err, eval.ErrRejected) {
+			errorType = semconv.ErrorTypeKey.String("rejected")
 		}
+		attrs = append(attrs, errorType)
+
+		span := trace.SpanFromContext(ctx)
+		span.RecordError(err)
+		span.SetAttributes(errorType)
 	}
+	c.evalDuration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	if err != nil {
+		return c.enqueueSolution(ctx, solution{
+			ID:     p.ID,
+			Status: statusFailed,
+			Error:  err.Error(),
+		})
+	}
+
+	return c.enqueueSolution(ctx, solution{
+		ID:      p.ID,
+		Status:  statusCompleted,
+		Result:  result.Value,
+		Decimal: result.Decimal,
+	})
 }
 
 func (c *calculator) enqueueSolution(ctx context.Context, s solution) error {
@@ -129,12 +129,12 @@ func (c *calculator) enqueueSolution(ctx context.Context, s solution) error {
 	opts := []trace.SpanStartOption{
 		trace.WithSpanKind(trace.SpanKindProducer),
 		trace.WithAttributes(
-			semconv.MessagingSystemKey.String("elasticmq"),
+			semconv.MessagingSystemKey.String(queue.MessagingSystem),
 			semconv.MessagingDestinationName(path.Base(c.writeQueueUrl)),
 		),
 	}
 	ctx, span := otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s send", path.Base(c.writeQueueUrl)), opts...)
-	otelsqs.NewCarrier().Inject(ctx, sMInput.MessageAttributes)
+	queue.NewCarrier().Inject(ctx, sMInput.MessageAttributes)
 	defer span.End()
 
 	resp, err := c.client.SendMessage(ctx, sMInput)
@@ -150,21 +150,77 @@ func (c *calculator) enqueueSolution(ctx context.Context, s solution) error {
 }
 
 func main() {
-	ctx := context.Background()
+	if err := run(); err != nil {
+		slog.Error("Exiting", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTimeout := 10 * time.Second
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		shutdownTimeout = d
+	}
+
+	// Cleanups run in reverse order and share a single deadline.
+	var cleanups []func(context.Context)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i](ctx)
+		}
+	}()
+
+	// Register global logger provider first so it is flushed last.
+	lp, err := otelcommon.InitLogger(ctx, otelcommon.Config{
+		ServiceName:    "calculator",
+		ServiceVersion: "v0.0.1",
+	})
+	if err != nil {
+		return err
+	}
+	cleanups = append(cleanups, func(ctx context.Context) {
+		if err := lp.Shutdown(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error shutting down logger provider", "error", err)
+		}
+	})
 
 	// Register global trace provider.
 	tp, err := otelcommon.InitTracer(ctx, otelcommon.Config{
 		ServiceName:    "calculator",
 		ServiceVersion: "v0.0.1",
+		BaggageKeys:    []string{otelcommon.BaggageStudent, otelcommon.BaggageRequestID},
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer func() {
+	cleanups = append(cleanups, func(ctx context.Context) {
 		if err := tp.Shutdown(ctx); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+			slog.ErrorContext(ctx, "Error shutting down tracer provider", "error", err)
 		}
-	}()
+	})
+
+	// Register global meter provider.
+	mp, err := otelcommon.InitMeter(ctx, otelcommon.Config{
+		ServiceName:    "calculator",
+		ServiceVersion: "v0.0.1",
+	})
+	if err != nil {
+		return err
+	}
+	cleanups = append(cleanups, func(ctx context.Context) {
+		if err := mp.Shutdown(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error shutting down meter provider", "error", err)
+		}
+	})
 
 	c := sqs.New(sqs.Options{
 		Region:       os.Getenv("SQS_REGION"),
@@ -175,7 +231,7 @@ func main() {
 		QueueName: aws.String(os.Getenv("SQS_READ_QUEUE_NAME")),
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	readQueueUrl := *resp.QueueUrl
@@ -184,16 +240,86 @@ func main() {
 		QueueName: aws.String(os.Getenv("SQS_WRITE_QUEUE_NAME")),
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	writeQueueUrl := *resp.QueueUrl
 
+	var deadLetterQueueUrl string
+	if name := os.Getenv("SQS_DEAD_LETTER_QUEUE_NAME"); name != "" {
+		resp, err = c.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+			QueueName: aws.String(name),
+		})
+		if err != nil {
+			return err
+		}
+
+		deadLetterQueueUrl = *resp.QueueUrl
+	}
+
+	maxReceiveCount, _ := strconv.Atoi(os.Getenv("SQS_MAX_RECEIVE_COUNT"))
+	maxNumberOfMessages, _ := strconv.Atoi(os.Getenv("SQS_MAX_NUMBER_OF_MESSAGES"))
+
+	workers, _ := strconv.Atoi(os.Getenv("CALCULATOR_WORKERS"))
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	maxExpressionLength, _ := strconv.Atoi(os.Getenv("CALCULATOR_MAX_EXPRESSION_LENGTH"))
+	maxDepth, _ := strconv.Atoi(os.Getenv("CALCULATOR_MAX_DEPTH"))
+	maxOps, _ := strconv.Atoi(os.Getenv("CALCULATOR_MAX_OPERATIONS"))
+	evalTimeout, _ := time.ParseDuration(os.Getenv("CALCULATOR_EVALUATION_TIMEOUT"))
+
+	limits := eval.Limits{
+		MaxLength: maxExpressionLength,
+		MaxDepth:  maxDepth,
+		MaxOps:    maxOps,
+		Timeout:   evalTimeout,
+	}
+
+	evalDuration, err := otelcommon.Meter().Float64Histogram("calculator.evaluation.duration",
+		metric.WithDescription("The time spent evaluating an expression."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
 	calc := calculator{
 		client:        c,
-		readQueueUrl:  readQueueUrl,
 		writeQueueUrl: writeQueueUrl,
+		sandboxes: map[string]*eval.Sandbox{
+			modeFloat:   eval.NewSandbox(eval.Goval{}, limits),
+			modeDecimal: eval.NewSandbox(eval.Decimal{}, limits),
+		},
+		evalDuration: evalDuration,
 	}
 
-	log.Fatal(calc.process(ctx))
+	consumer := queue.NewConsumer(queue.Config{
+		Client:              c,
+		QueueURL:            readQueueUrl,
+		MaxNumberOfMessages: int32(maxNumberOfMessages),
+		Workers:             workers,
+		DeadLetterQueueURL:  deadLetterQueueUrl,
+		MaxReceiveCount:     maxReceiveCount,
+		TraceMode:           queue.TraceMode(os.Getenv("SQS_TRACE_MODE")),
+	}, calc.solve)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.Run(ctx)
+	}()
+	cleanups = append(cleanups, func(ctx context.Context) {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "Timed out draining queue messages")
+		}
+	})
+
+	<-ctx.Done()
+	// Restore the default signal handling, so that a second signal exits
+	// without waiting for the cleanups.
+	stop()
+	slog.InfoContext(ctx, "Shutting down")
+	return nil
 }
@@ -5,8 +5,9 @@ import (
 	"context"
 	"encoding/json"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"os"
 
 	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
 	"github.com/google/uuid"
@@ -23,58 +24,75 @@ type CreateCalculationResponse struct {
 }
 
 func main() {
+	if err := run(); err != nil {
+		slog.Error("Exiting", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
 	ctx := context.Background()
 
+	// Register global logger provider.
+	lp, err := otelcommon.InitLogger(ctx, otelcommon.Config{
+		ServiceName:    "client",
+		ServiceVersion: "v0.0.1",
+	})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lp.Shutdown(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error shutting down logger provider", "error", err)
+		}
+	}()
+
 	//Register global trace provider.
 	tp, err := otelcommon.InitTracer(ctx, otelcommon.Config{
 		ServiceName:    "client",
 		ServiceVersion: "v0.0.1",
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer func() {
 		if err := tp.Shutdown(ctx); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+			slog.ErrorContext(ctx, "Error shutting down tracer provider", "error", err)
 		}
 	}()
 
-	if err := func() error {
-		url := "http://localhost:80/calculations"
-		calc := CreateCalculationRequest{
-			Expression: "8 + 12",
-			Student:    "go client",
-		}
-		b, err := json.Marshal(calc)
-		if err != nil {
-			return err
-		}
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
-		if err != nil {
-			return err
-		}
+	url := "http://localhost:80/calculations"
+	calc := CreateCalculationRequest{
+		Expression: "8 + 12",
+		Student:    "go client",
+	}
+	b, err := json.Marshal(calc)
+	if err != nil {
+		return err
+	}
 
-		client := http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
-		res, err := client.Do(req)
-		if err != nil {
-			return err
-		}
-		defer res.Body.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
 
-		b, err = io.ReadAll(res.Body)
-		if err != nil {
-			return err
-		}
+	client := http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
 
-		var resp CreateCalculationResponse
-		if err := json.Unmarshal(b, &resp); err != nil {
-			return err
-		}
+	b, err = io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
 
-		log.Println("Calculation Id:", resp.Id)
-		return nil
-	}(); err != nil {
-		log.Fatal(err)
+	var resp CreateCalculationResponse
+	if err := json.Unmarshal(b, &resp); err != nil {
+		return err
 	}
+
+	slog.InfoContext(ctx, "Created calculation", "id", resp.Id)
+	return nil
 }
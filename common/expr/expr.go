@@ -0,0 +1,99 @@
+// Package expr describes the expression language shared by the server, which
+// validates expressions, and the calculator, which evaluates them.
+package expr
+
+import (
+	"go/scanner"
+	"go/token"
+	"math"
+	"regexp"
+)
+
+// Function is a function expressions may call.
+type Function struct {
+	MinArgs int
+	// MaxArgs is -1 for variadic functions.
+	MaxArgs int
+	Call    func(args ...float64) float64
+}
+
+// Functions is the whitelist of functions expressions may call.
+var Functions = map[string]Function{
+	"sqrt":  {1, 1, func(a ...float64) float64 { return math.Sqrt(a[0]) }},
+	"pow":   {2, 2, func(a ...float64) float64 { return math.Pow(a[0], a[1]) }},
+	"min":   {1, -1, minimum},
+	"max":   {1, -1, maximum},
+	"abs":   {1, 1, func(a ...float64) float64 { return math.Abs(a[0]) }},
+	"round": {1, 1, func(a ...float64) float64 { return math.Round(a[0]) }},
+	"log":   {1, 1, func(a ...float64) float64 { return math.Log(a[0]) }},
+	"log10": {1, 1, func(a ...float64) float64 { return math.Log10(a[0]) }},
+	"sin":   {1, 1, func(a ...float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, 1, func(a ...float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, 1, func(a ...float64) float64 { return math.Tan(a[0]) }},
+	"asin":  {1, 1, func(a ...float64) float64 { return math.Asin(a[0]) }},
+	"acos":  {1, 1, func(a ...float64) float64 { return math.Acos(a[0]) }},
+	"atan":  {1, 1, func(a ...float64) float64 { return math.Atan(a[0]) }},
+	"atan2": {2, 2, func(a ...float64) float64 { return math.Atan2(a[0], a[1]) }},
+}
+
+func minimum(a ...float64) float64 {
+	m := a[0]
+	for _, v := range a[1:] {
+		m = math.Min(m, v)
+	}
+	return m
+}
+
+func maximum(a ...float64) float64 {
+	m := a[0]
+	for _, v := range a[1:] {
+		m = math.Max(m, v)
+	}
+	return m
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// IsIdentifier reports whether name can be used as a variable.
+func IsIdentifier(name string) bool {
+	if !identifier.MatchString(name) || token.IsKeyword(name) {
+		return false
+	}
+	_, isFunction := Functions[name]
+	return !isFunction && !isLiteral(name)
+}
+
+// UnknownIdentifiers returns, in order of first use, the identifiers in
+// expression that name neither a variable nor a Function.
+func UnknownIdentifiers(expression string, variables map[string]float64) []string {
+	var sc scanner.Scanner
+	fset := token.NewFileSet()
+	sc.Init(fset.AddFile("", fset.Base(), len(expression)), []byte(expression), nil, 0)
+
+	var unknown []string
+	seen := map[string]bool{}
+	prev := token.ILLEGAL
+	for {
+		_, tok, lit := sc.Scan()
+		if tok == token.EOF {
+			return unknown
+		}
+
+		// Keywords are identifiers to the evaluator, and a name after a
+		// period is a member rather than a variable.
+		if (tok == token.IDENT || tok.IsKeyword()) && prev != token.PERIOD && !seen[lit] {
+			seen[lit] = true
+			_, isVariable := variables[lit]
+			_, isFunction := Functions[lit]
+			if !isVariable && !isFunction && !isLiteral(lit) {
+				unknown = append(unknown, lit)
+			}
+		}
+
+		prev = tok
+	}
+}
+
+func isLiteral(name string) bool {
+	return name == "true" || name == "false" || name == "nil"
+}
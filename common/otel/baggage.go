@@ -0,0 +1,50 @@
+package otel
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Baggage keys set by the server for each calculation.
+const (
+	BaggageStudent   = "student"
+	BaggageRequestID = "request.id"
+)
+
+// WithBaggage returns a copy of ctx whose baggage also holds key=value. The
+// value is left out if it can't be carried.
+func WithBaggage(ctx context.Context, key, value string) context.Context {
+	m, err := baggage.NewMemberRaw(key, value)
+	if err != nil {
+		return ctx
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, b)
+}
+
+// baggageSpanProcessor copies baggage members of the parent context onto
+// each span as attributes of the same name.
+type baggageSpanProcessor struct {
+	keys []string
+}
+
+func (p baggageSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	b := baggage.FromContext(ctx)
+	for _, key := range p.keys {
+		if m := b.Member(key); m.Key() != "" {
+			s.SetAttributes(attribute.String(key, m.Value()))
+		}
+	}
+}
+
+func (baggageSpanProcessor) OnEnd(sdktrace.ReadOnlySpan) {}
+
+func (baggageSpanProcessor) Shutdown(context.Context) error { return nil }
+
+func (baggageSpanProcessor) ForceFlush(context.Context) error { return nil }
@@ -0,0 +1,125 @@
+package otel
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploggrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlplog/otlploghttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// The exporters read the rest of the OTEL_EXPORTER_OTLP_* variables
+// themselves: endpoint, insecure, certificate, client key and certificate,
+// headers, compression and timeout, each with a per-signal override.
+
+const (
+	protocolGRPC = "grpc"
+	protocolHTTP = "http/protobuf"
+)
+
+// otlpProtocol returns the protocol set by OTEL_EXPORTER_OTLP_<signal>_PROTOCOL
+// or OTEL_EXPORTER_OTLP_PROTOCOL. It defaults to grpc, rather than the
+// specification's http/protobuf, as the collector has always been reached
+// over gRPC.
+func otlpProtocol(signal string) (string, error) {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_" + signal + "_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+
+	switch protocol {
+	case "", protocolGRPC:
+		return protocolGRPC, nil
+	case protocolHTTP:
+		return protocolHTTP, nil
+	}
+	return "", fmt.Errorf("unsupported OTLP protocol %q", protocol)
+}
+
+// insecureByDefault reports whether neither an endpoint nor TLS is
+// configured for signal. The exporters then connect to localhost over TLS,
+// though the specification's default endpoint is plain http.
+func insecureByDefault(signal string) bool {
+	for _, key := range []string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_" + signal + "_ENDPOINT",
+		"OTEL_EXPORTER_OTLP_INSECURE",
+		"OTEL_EXPORTER_OTLP_" + signal + "_INSECURE",
+	} {
+		if os.Getenv(key) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func newTraceExporter(ctx context.Context) (sdktrace.SpanExporter, error) {
+	protocol, err := otlpProtocol("TRACES")
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == protocolHTTP {
+		var opts []otlptracehttp.Option
+		if insecureByDefault("TRACES") {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	}
+
+	var opts []otlptracegrpc.Option
+	if insecureByDefault("TRACES") {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+func newMetricExporter(ctx context.Context) (sdkmetric.Exporter, error) {
+	protocol, err := otlpProtocol("METRICS")
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == protocolHTTP {
+		var opts []otlpmetrichttp.Option
+		if insecureByDefault("METRICS") {
+			opts = append(opts, otlpmetrichttp.WithInsecure())
+		}
+		return otlpmetrichttp.New(ctx, opts...)
+	}
+
+	var opts []otlpmetricgrpc.Option
+	if insecureByDefault("METRICS") {
+		opts = append(opts, otlpmetricgrpc.WithInsecure())
+	}
+	return otlpmetricgrpc.New(ctx, opts...)
+}
+
+func newLogExporter(ctx context.Context) (sdklog.Exporter, error) {
+	protocol, err := otlpProtocol("LOGS")
+	if err != nil {
+		return nil, err
+	}
+
+	if protocol == protocolHTTP {
+		var opts []otlploghttp.Option
+		if insecureByDefault("LOGS") {
+			opts = append(opts, otlploghttp.WithInsecure())
+		}
+		return otlploghttp.New(ctx, opts...)
+	}
+
+	var opts []otlploggrpc.Option
+	if insecureByDefault("LOGS") {
+		opts = append(opts, otlploggrpc.WithInsecure())
+	}
+	return otlploggrpc.New(ctx, opts...)
+}
@@ -0,0 +1,102 @@
+package otel
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/contrib/bridges/otelslog"
+	"go.opentelemetry.io/otel/log/global"
+	sdklog "go.opentelemetry.io/otel/sdk/log"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InitLogger registers a global LoggerProvider and makes the default slog
+// logger (and with it the log package) write to both stderr and OTLP.
+func InitLogger(ctx context.Context, cfg Config) (*sdklog.LoggerProvider, error) {
+	exporter, err := newLogExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := newResource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	lp := sdklog.NewLoggerProvider(
+		sdklog.WithProcessor(sdklog.NewBatchProcessor(exporter)),
+		sdklog.WithResource(resource),
+	)
+	global.SetLoggerProvider(lp)
+
+	slog.SetDefault(slog.New(fanoutHandler{
+		traceHandler{slog.NewJSONHandler(os.Stderr, nil)},
+		otelslog.NewHandler("nola-otel-demo", otelslog.WithLoggerProvider(lp)),
+	}))
+
+	return lp, nil
+}
+
+// traceHandler stamps records with the trace_id and span_id of the span in
+// the record's context. The OTLP bridge does this natively.
+type traceHandler struct {
+	slog.Handler
+}
+
+func (h traceHandler) Handle(ctx context.Context, r slog.Record) error {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h traceHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return traceHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h traceHandler) WithGroup(name string) slog.Handler {
+	return traceHandler{h.Handler.WithGroup(name)}
+}
+
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, h := range f {
+		if h.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	for _, h := range f {
+		if h.Enabled(ctx, r.Level) {
+			if herr := h.Handle(ctx, r.Clone()); herr != nil && err == nil {
+				err = herr
+			}
+		}
+	}
+	return err
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	hs := make(fanoutHandler, len(f))
+	for i, h := range f {
+		hs[i] = h.WithAttrs(attrs)
+	}
+	return hs
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	hs := make(fanoutHandler, len(f))
+	for i, h := range f {
+		hs[i] = h.WithGroup(name)
+	}
+	return hs
+}
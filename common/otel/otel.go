@@ -2,49 +2,128 @@ package otel
 
 import (
 	"context"
+	"os"
 
+	"go.opentelemetry.io/contrib/propagators/autoprop"
 	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Config struct {
 	ServiceName    string
 	ServiceVersion string
+	// BaggageKeys names the baggage members InitTracer copies onto every
+	// span as attributes, so spans can be searched by them in each service
+	// the baggage reaches.
+	BaggageKeys []string
+	// Sampler decides which spans are exported. Spans that end in an error
+	// are exported even if it drops them, provided it records them, as
+	// RuleSampler does by default. Every recorded span costs about as much
+	// as a sampled one until it ends, so keeping errors trades most of the
+	// CPU and memory sampling would save for never missing a failure; set
+	// TRACES_SAMPLER_KEEP_ERRORS=false, or RuleSampler.DropErrors, where
+	// that cost matters more. Defaults to a RuleSampler configured by the
+	// environment; see samplerFromEnv.
+	Sampler sdktrace.Sampler
 }
 
+// InitTracer registers a global TracerProvider and propagator. The propagator
+// is chosen by OTEL_PROPAGATORS, a comma-separated list of tracecontext,
+// baggage, b3, b3multi, jaeger, xray and ottrace, and defaults to
+// tracecontext,baggage. HTTP requests and queue messages both use it.
 func InitTracer(ctx context.Context, cfg Config) (*sdktrace.TracerProvider, error) {
-	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithInsecure())
+	propagator, err := newPropagator()
 	if err != nil {
 		return nil, err
 	}
 
-	resource, err := resource.Merge(
-		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceName(cfg.ServiceName),
-			semconv.ServiceVersion(cfg.ServiceVersion),
-		),
-	)
+	sampler := cfg.Sampler
+	if sampler == nil {
+		if sampler, err = samplerFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+
+	exporter, err := newTraceExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := newResource(cfg)
 	if err != nil {
 		return nil, err
 	}
 
 	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSpanProcessor(baggageSpanProcessor{keys: cfg.BaggageKeys}),
+		sdktrace.WithSpanProcessor(errorSpanProcessor{sdktrace.NewBatchSpanProcessor(exporter)}),
+		sdktrace.WithSampler(sampler),
 		sdktrace.WithResource(resource),
 	)
 	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otel.SetTextMapPropagator(propagator)
 
 	return tp, nil
 }
 
+// newPropagator parses OTEL_PROPAGATORS itself, rather than leaving it to
+// autoprop, so that an unknown name fails startup instead of being ignored.
+func newPropagator() (propagation.TextMapPropagator, error) {
+	names := os.Getenv("OTEL_PROPAGATORS")
+	if names == "" {
+		return autoprop.NewTextMapPropagator(), nil
+	}
+
+	return autoprop.TextMapPropagator(splitList(names)...)
+}
+
+func InitMeter(ctx context.Context, cfg Config) (*sdkmetric.MeterProvider, error) {
+	exporter, err := newMetricExporter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	resource, err := newResource(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)),
+		sdkmetric.WithResource(resource),
+	)
+	otel.SetMeterProvider(mp)
+
+	return mp, nil
+}
+
+// newResource describes the service by cfg, overridden by
+// OTEL_RESOURCE_ATTRIBUTES and OTEL_SERVICE_NAME.
+func newResource(cfg Config) (*resource.Resource, error) {
+	r, err := resource.Merge(
+		resource.Default(),
+		resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceName(cfg.ServiceName),
+			semconv.ServiceVersion(cfg.ServiceVersion),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resource.Merge(r, resource.Environment())
+}
+
 func Tracer() trace.Tracer {
 	return otel.Tracer("nola-otel-demo")
 }
+
+func Meter() metric.Meter {
+	return otel.Meter("nola-otel-demo")
+}
@@ -0,0 +1,177 @@
+package otel
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RuleSampler samples every span of one of Students or for one of Routes,
+// and leaves the others to Fallback. Spans Fallback drops are still
+// recorded, unless DropErrors is set, so that InitTracer can export those
+// that end in an error.
+type RuleSampler struct {
+	// Students are matched against the student baggage member and span
+	// attribute.
+	Students []string
+	// Routes are matched against the http.route span attribute.
+	Routes []string
+	// Fallback defaults to parent-based always on.
+	Fallback sdktrace.Sampler
+	// DropErrors drops the spans Fallback drops, rather than recording
+	// them in case they end in an error. Recorded spans cost as much to
+	// build as sampled ones, attributes, events and all, so keeping errors
+	// gives up most of the CPU and memory a low sampling ratio saves; only
+	// the export is spared.
+	DropErrors bool
+}
+
+func (s RuleSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if s.matches(p) {
+		return sdktrace.SamplingResult{
+			Decision:   sdktrace.RecordAndSample,
+			Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+		}
+	}
+
+	fallback := s.Fallback
+	if fallback == nil {
+		fallback = sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+
+	result := fallback.ShouldSample(p)
+	if !s.DropErrors && result.Decision == sdktrace.Drop {
+		result.Decision = sdktrace.RecordOnly
+	}
+	return result
+}
+
+func (s RuleSampler) matches(p sdktrace.SamplingParameters) bool {
+	student := baggage.FromContext(p.ParentContext).Member(BaggageStudent).Value()
+	var route string
+	for _, attr := range p.Attributes {
+		switch attr.Key {
+		case BaggageStudent:
+			student = attr.Value.AsString()
+		case semconv.HTTPRouteKey:
+			route = attr.Value.AsString()
+		}
+	}
+
+	return (student != "" && slices.Contains(s.Students, student)) ||
+		(route != "" && slices.Contains(s.Routes, route))
+}
+
+func (s RuleSampler) Description() string {
+	fallback := "ParentBased{root:AlwaysOnSampler}"
+	if s.Fallback != nil {
+		fallback = s.Fallback.Description()
+	}
+	return fmt.Sprintf("RuleSampler{students:%v,routes:%v,fallback:%s,dropErrors:%t}", s.Students, s.Routes, fallback, s.DropErrors)
+}
+
+// errorSpanProcessor passes the spans a sampler dropped but that ended in an
+// error on to the wrapped processor as if they had been sampled.
+type errorSpanProcessor struct {
+	sdktrace.SpanProcessor
+}
+
+func (p errorSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if !s.SpanContext().IsSampled() {
+		if s.Status().Code != codes.Error {
+			return
+		}
+		s = sampledSpan{s}
+	}
+	p.SpanProcessor.OnEnd(s)
+}
+
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
+
+// samplerFromEnv returns a RuleSampler keeping the comma-separated students
+// in TRACES_SAMPLER_KEEP_STUDENTS and routes in TRACES_SAMPLER_KEEP_ROUTES,
+// and keeping errors unless TRACES_SAMPLER_KEEP_ERRORS is false. Its fallback
+// is the standard sampler named by OTEL_TRACES_SAMPLER and
+// OTEL_TRACES_SAMPLER_ARG.
+func samplerFromEnv() (sdktrace.Sampler, error) {
+	fallback, err := standardSampler(os.Getenv("OTEL_TRACES_SAMPLER"), os.Getenv("OTEL_TRACES_SAMPLER_ARG"))
+	if err != nil {
+		return nil, err
+	}
+
+	keepErrors, err := strconv.ParseBool(os.Getenv("TRACES_SAMPLER_KEEP_ERRORS"))
+	if err != nil {
+		keepErrors = true
+	}
+
+	return RuleSampler{
+		Students:   splitList(os.Getenv("TRACES_SAMPLER_KEEP_STUDENTS")),
+		Routes:     splitList(os.Getenv("TRACES_SAMPLER_KEEP_ROUTES")),
+		Fallback:   fallback,
+		DropErrors: !keepErrors,
+	}, nil
+}
+
+// standardSampler parses the samplers of the OpenTelemetry environment
+// variable specification, defaulting to parentbased_always_on.
+func standardSampler(name, arg string) (sdktrace.Sampler, error) {
+	ratio := func() (float64, error) {
+		if arg == "" {
+			return 1, nil
+		}
+		r, err := strconv.ParseFloat(arg, 64)
+		if err != nil || r < 0 || r > 1 {
+			return 0, fmt.Errorf("OTEL_TRACES_SAMPLER_ARG must be a ratio between 0 and 1, got %q", arg)
+		}
+		return r, nil
+	}
+
+	switch name {
+	case "", "parentbased_always_on":
+		return sdktrace.ParentBased(sdktrace.AlwaysSample()), nil
+	case "parentbased_always_off":
+		return sdktrace.ParentBased(sdktrace.NeverSample()), nil
+	case "parentbased_traceidratio":
+		r, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(r)), nil
+	case "always_on":
+		return sdktrace.AlwaysSample(), nil
+	case "always_off":
+		return sdktrace.NeverSample(), nil
+	case "traceidratio":
+		r, err := ratio()
+		if err != nil {
+			return nil, err
+		}
+		return sdktrace.TraceIDRatioBased(r), nil
+	}
+	return nil, fmt.Errorf("unsupported OTEL_TRACES_SAMPLER %q", name)
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,125 @@
+package otel
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestRuleSampler(t *testing.T) {
+	tests := []struct {
+		name    string
+		sampler RuleSampler
+		student string
+		route   string
+		want    sdktrace.SamplingDecision
+	}{
+		{
+			name:    "kept student",
+			sampler: RuleSampler{Students: []string{"ada"}, Fallback: sdktrace.NeverSample()},
+			student: "ada",
+			want:    sdktrace.RecordAndSample,
+		},
+		{
+			name:    "kept route",
+			sampler: RuleSampler{Routes: []string{"/calculations"}, Fallback: sdktrace.NeverSample()},
+			route:   "/calculations",
+			want:    sdktrace.RecordAndSample,
+		},
+		{
+			name:    "sampled by the fallback",
+			sampler: RuleSampler{Students: []string{"ada"}},
+			student: "grace",
+			want:    sdktrace.RecordAndSample,
+		},
+		{
+			name:    "recorded for errors",
+			sampler: RuleSampler{Students: []string{"ada"}, Fallback: sdktrace.NeverSample()},
+			student: "grace",
+			want:    sdktrace.RecordOnly,
+		},
+		{
+			name:    "dropped by the fallback",
+			sampler: RuleSampler{Students: []string{"ada"}, Fallback: sdktrace.NeverSample(), DropErrors: true},
+			student: "grace",
+			want:    sdktrace.Drop,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.student != "" {
+				ctx = WithBaggage(ctx, BaggageStudent, tt.student)
+			}
+			p := sdktrace.SamplingParameters{ParentContext: ctx, Name: "span"}
+			if tt.route != "" {
+				p.Attributes = append(p.Attributes, semconv.HTTPRoute(tt.route))
+			}
+
+			if got := tt.sampler.ShouldSample(p).Decision; got != tt.want {
+				t.Errorf("decision is %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorSpanProcessorExportsRecordedErrors(t *testing.T) {
+	for _, dropErrors := range []bool{false, true} {
+		exporter := tracetest.NewInMemoryExporter()
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithSpanProcessor(errorSpanProcessor{sdktrace.NewSimpleSpanProcessor(exporter)}),
+			sdktrace.WithSampler(RuleSampler{Fallback: sdktrace.NeverSample(), DropErrors: dropErrors}),
+		)
+		tracer := tp.Tracer("test")
+
+		_, ok := tracer.Start(context.Background(), "ok")
+		ok.End()
+		_, failed := tracer.Start(context.Background(), "failed")
+		failed.SetStatus(codes.Error, "failed")
+		failed.End()
+
+		spans := exporter.GetSpans()
+		if dropErrors {
+			if len(spans) != 0 {
+				t.Errorf("exported %d spans with DropErrors, want none", len(spans))
+			}
+			continue
+		}
+		if len(spans) != 1 || spans[0].Name != "failed" {
+			t.Fatalf("exported %v, want only the failed span", spans.Snapshots())
+		}
+		if !spans[0].SpanContext.IsSampled() {
+			t.Error("exported span is not marked sampled")
+		}
+	}
+}
+
+func TestSamplerFromEnvKeepsErrorsByDefault(t *testing.T) {
+	tests := []struct {
+		keepErrors     string
+		wantDropErrors bool
+	}{
+		{keepErrors: "", wantDropErrors: false},
+		{keepErrors: "true", wantDropErrors: false},
+		{keepErrors: "false", wantDropErrors: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.keepErrors, func(t *testing.T) {
+			t.Setenv("TRACES_SAMPLER_KEEP_ERRORS", tt.keepErrors)
+
+			sampler, err := samplerFromEnv()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := sampler.(RuleSampler).DropErrors; got != tt.wantDropErrors {
+				t.Errorf("DropErrors is %t, want %t", got, tt.wantDropErrors)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package queue
+
+import (
+	"context"
+	"slices"
+
+	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewCarrier returns a carrier of trace context in SQS message attributes.
+// It uses the global propagator, so messages carry the same fields as HTTP
+// requests rather than otelsqs's default of b3.
+func NewCarrier() *otelsqs.SqsCarrierAttributes {
+	return otelsqs.NewCarrier().WithPropagator(otel.GetTextMapPropagator())
+}
+
+// propagationFields returns the message attributes the global propagator
+// may set. Its Fields alone aren't enough: the b3 propagator sets the single
+// b3 header but only reports the multiple-header fields.
+func propagationFields() []string {
+	propagator := otel.GetTextMapPropagator()
+	fields := propagator.Fields()
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	})
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(trace.ContextWithSpanContext(context.Background(), sc), carrier)
+	for _, key := range carrier.Keys() {
+		if !slices.Contains(fields, key) {
+			fields = append(fields, key)
+		}
+	}
+
+	return fields
+}
@@ -0,0 +1,150 @@
+package queue
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/contrib/propagators/autoprop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TestTraceStaysConnected follows a calculation from the client over HTTP
+// to the server, over the queue to the calculator and back to the server,
+// with each propagator OTEL_PROPAGATORS can choose.
+func TestTraceStaysConnected(t *testing.T) {
+	for _, name := range []string{"tracecontext", "b3", "b3multi", "jaeger", "xray"} {
+		for _, mode := range []TraceMode{TraceModeParent, TraceModeLink} {
+			t.Run(name+"/"+string(mode), func(t *testing.T) {
+				testTraceStaysConnected(t, name, mode)
+			})
+		}
+	}
+}
+
+func testTraceStaysConnected(t *testing.T, propagator string, mode TraceMode) {
+	prop, err := autoprop.TextMapPropagator(propagator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(prop)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	tracer := tp.Tracer("test")
+
+	// Client to server over HTTP.
+	clientCtx, clientSpan := tracer.Start(context.Background(), "client")
+	header := http.Header{}
+	otel.GetTextMapPropagator().Inject(clientCtx, propagation.HeaderCarrier(header))
+	serverCtx, serverSpan := tracer.Start(otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(header)), "server")
+
+	// Server to calculator over the queue.
+	calcCtx, calcSpan := consume(t, mode, send(t, serverCtx))
+
+	// Calculator back to the server over the queue.
+	_, resultSpan := consume(t, mode, send(t, calcCtx))
+
+	resultSpan.End()
+	calcSpan.End()
+	serverSpan.End()
+	clientSpan.End()
+
+	assertChild(t, recorder, "server", serverSpan, clientSpan)
+	switch mode {
+	case TraceModeLink:
+		assertLinked(t, recorder, "calculator", calcSpan, serverSpan)
+		assertLinked(t, recorder, "result", resultSpan, calcSpan)
+	default:
+		assertChild(t, recorder, "calculator", calcSpan, serverSpan)
+		assertChild(t, recorder, "result", resultSpan, calcSpan)
+	}
+}
+
+// send injects the trace context of ctx into message attributes, checking
+// that a consumer requests every attribute that was set.
+func send(t *testing.T, ctx context.Context) map[string]types.MessageAttributeValue {
+	t.Helper()
+
+	attrs := map[string]types.MessageAttributeValue{}
+	NewCarrier().Inject(ctx, attrs)
+	if len(attrs) == 0 {
+		t.Fatal("no trace context was injected")
+	}
+
+	fields := propagationFields()
+	for key := range attrs {
+		if !slices.Contains(fields, key) {
+			t.Errorf("attribute %q is set but not requested on receive", key)
+		}
+	}
+	return attrs
+}
+
+func consume(t *testing.T, mode TraceMode, attrs map[string]types.MessageAttributeValue) (context.Context, trace.Span) {
+	t.Helper()
+
+	c := NewConsumer(Config{QueueURL: testQueueURL, TraceMode: mode}, func(context.Context, testMessage) error {
+		return nil
+	})
+	return c.startSpan(context.Background(), types.Message{
+		MessageId:         aws.String("message"),
+		MessageAttributes: attrs,
+	})
+}
+
+// recorded returns the ended span the recorder holds for span.
+func recorded(t *testing.T, recorder *tracetest.SpanRecorder, name string, span trace.Span) sdktrace.ReadOnlySpan {
+	t.Helper()
+
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().Equal(span.SpanContext()) {
+			return s
+		}
+	}
+	t.Fatalf("%s span was not recorded", name)
+	return nil
+}
+
+func assertChild(t *testing.T, recorder *tracetest.SpanRecorder, name string, child, parent trace.Span) {
+	t.Helper()
+
+	got := recorded(t, recorder, name, child).Parent()
+	want := parent.SpanContext()
+	if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() {
+		t.Errorf("%s span's parent is %s/%s, want %s/%s", name, got.TraceID(), got.SpanID(), want.TraceID(), want.SpanID())
+	}
+}
+
+func assertLinked(t *testing.T, recorder *tracetest.SpanRecorder, name string, span, producer trace.Span) {
+	t.Helper()
+
+	ro := recorded(t, recorder, name, span)
+	if ro.Parent().IsValid() {
+		t.Errorf("%s span has a parent, want a new trace", name)
+	}
+	if ro.SpanContext().TraceID() == producer.SpanContext().TraceID() {
+		t.Errorf("%s span shares its producer's trace, want a new trace", name)
+	}
+
+	want := producer.SpanContext()
+	for _, link := range ro.Links() {
+		if link.SpanContext.TraceID() == want.TraceID() && link.SpanContext.SpanID() == want.SpanID() {
+			return
+		}
+	}
+	t.Errorf("%s span is not linked to %s/%s", name, want.TraceID(), want.SpanID())
+}
@@ -0,0 +1,459 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// MessagingSystem is the messaging.system attribute reported on queue spans.
+const MessagingSystem = "elasticmq"
+
+// Handler processes a single decoded message. A nil return acknowledges the
+// message and removes it from the queue.
+type Handler[T any] func(ctx context.Context, msg T) error
+
+// Client is the part of *sqs.Client a Consumer uses.
+type Client interface {
+	ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	SendMessage(context.Context, *sqs.SendMessageInput, ...func(*sqs.Options)) (*sqs.SendMessageOutput, error)
+	ChangeMessageVisibility(context.Context, *sqs.ChangeMessageVisibilityInput, ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error)
+}
+
+type Config struct {
+	Client            Client
+	QueueURL          string
+	VisibilityTimeout int32
+	WaitTimeSeconds   int32
+	// MaxNumberOfMessages is the receive batch size, at most 10.
+	MaxNumberOfMessages int32
+	// Workers is the number of messages handled concurrently. Defaults to 1.
+	Workers int
+	// DeadLetterQueueURL receives messages that failed MaxReceiveCount times
+	// or failed permanently. Without it such messages are left on the queue,
+	// to be redelivered or moved by the queue's own redrive policy.
+	DeadLetterQueueURL string
+	MaxReceiveCount    int
+	// OnError is called for every message that fails. Defaults to logging
+	// with the default slog logger.
+	OnError func(context.Context, error)
+	// TraceMode chooses how a message's process span relates to the span
+	// that produced it. Defaults to TraceModeParent.
+	TraceMode TraceMode
+}
+
+type TraceMode string
+
+const (
+	// TraceModeParent makes the process span a child of the producer, so
+	// the trace lasts as long as the message waited in the queue.
+	TraceModeParent TraceMode = "parent"
+	// TraceModeLink starts each process span in a new trace with a link to
+	// the producer, as the messaging conventions recommend.
+	TraceModeLink TraceMode = "link"
+)
+
+type Consumer[T any] struct {
+	client              Client
+	queueUrl            string
+	visibilityTimeout   int32
+	waitTimeSeconds     int32
+	maxNumberOfMessages int32
+	workers             int
+	deadLetterQueueUrl  string
+	maxReceiveCount     int
+	onError             func(context.Context, error)
+	traceMode           TraceMode
+	handler             Handler[T]
+
+	processed metric.Int64Counter
+	failed    metric.Int64Counter
+}
+
+func NewConsumer[T any](cfg Config, handler Handler[T]) *Consumer[T] {
+	c := &Consumer[T]{
+		client:              cfg.Client,
+		queueUrl:            cfg.QueueURL,
+		visibilityTimeout:   cfg.VisibilityTimeout,
+		waitTimeSeconds:     cfg.WaitTimeSeconds,
+		maxNumberOfMessages: cfg.MaxNumberOfMessages,
+		workers:             cfg.Workers,
+		deadLetterQueueUrl:  cfg.DeadLetterQueueURL,
+		maxReceiveCount:     cfg.MaxReceiveCount,
+		onError:             cfg.OnError,
+		traceMode:           cfg.TraceMode,
+		handler:             handler,
+	}
+	if c.visibilityTimeout == 0 {
+		c.visibilityTimeout = 60
+	}
+	if c.waitTimeSeconds == 0 {
+		c.waitTimeSeconds = 10
+	}
+	if c.maxNumberOfMessages <= 0 {
+		c.maxNumberOfMessages = 1
+	} else if c.maxNumberOfMessages > 10 {
+		c.maxNumberOfMessages = 10
+	}
+	if c.workers <= 0 {
+		c.workers = 1
+	}
+	if c.maxReceiveCount == 0 {
+		c.maxReceiveCount = 5
+	}
+	if c.traceMode != TraceModeLink {
+		c.traceMode = TraceModeParent
+	}
+	if c.onError == nil {
+		c.onError = func(ctx context.Context, err error) {
+			slog.ErrorContext(ctx, "Unable to process queue message", "error", err)
+		}
+	}
+
+	var err error
+	c.processed, err = otelcommon.Meter().Int64Counter("queue.messages.processed",
+		metric.WithDescription("The number of queue messages handled successfully."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	c.failed, err = otelcommon.Meter().Int64Counter("queue.messages.failed",
+		metric.WithDescription("The number of queue messages that could not be handled."),
+		metric.WithUnit("{message}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return c
+}
+
+// Run long-polls the queue and hands every message to a pool of workers until
+// ctx is done. A failed message never stops the loop: it is left on the queue
+// for redelivery until it is dead-lettered. Messages are not handled in order.
+//
+// Once ctx is done Run stops receiving and returns after in-flight messages
+// have been handled; those messages are not cancelled with ctx.
+func (c *Consumer[T]) Run(ctx context.Context) error {
+	workCtx := context.WithoutCancel(ctx)
+
+	// Unbuffered, so at most one batch waits on busy workers.
+	msgs := make(chan types.Message)
+	var wg sync.WaitGroup
+	for i := 0; i < c.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range msgs {
+				c.process(workCtx, msg)
+			}
+		}()
+	}
+	defer func() {
+		close(msgs)
+		wg.Wait()
+	}()
+
+	input := &sqs.ReceiveMessageInput{
+		AttributeNames: []types.QueueAttributeName{
+			types.QueueAttributeName(types.MessageSystemAttributeNameApproximateReceiveCount),
+		},
+		MaxNumberOfMessages: c.maxNumberOfMessages,
+		// Request the fields the carrier extracts.
+		MessageAttributeNames: propagationFields(),
+		QueueUrl:              aws.String(c.queueUrl),
+		VisibilityTimeout:     c.visibilityTimeout,
+		WaitTimeSeconds:       c.waitTimeSeconds,
+	}
+
+	for {
+		start := time.Now()
+		resp, err := c.client.ReceiveMessage(ctx, input)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			c.onError(ctx, fmt.Errorf("receive from %s: %w", path.Base(c.queueUrl), err))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+
+		if len(resp.Messages) > 0 {
+			c.recordReceive(ctx, start, resp.Messages)
+		}
+
+		for i, msg := range resp.Messages {
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				c.release(workCtx, resp.Messages[i:])
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+// release makes messages that were received but never handled visible again
+// so another consumer can pick them up right away.
+func (c *Consumer[T]) release(ctx context.Context, msgs []types.Message) {
+	for _, msg := range msgs {
+		if _, err := c.client.ChangeMessageVisibility(ctx, &sqs.ChangeMessageVisibilityInput{
+			QueueUrl:          aws.String(c.queueUrl),
+			ReceiptHandle:     msg.ReceiptHandle,
+			VisibilityTimeout: 0,
+		}); err != nil {
+			c.onError(ctx, &Error{Kind: ErrKindAck, MessageID: aws.ToString(msg.MessageId), Err: err})
+		}
+	}
+}
+
+func (c *Consumer[T]) process(ctx context.Context, msg types.Message) {
+	ctx, span := c.startSpan(ctx, msg)
+	defer span.End()
+
+	destination := semconv.MessagingDestinationName(path.Base(c.queueUrl))
+
+	err := c.handle(ctx, msg)
+	if err == nil {
+		c.processed.Add(ctx, 1, metric.WithAttributes(destination))
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	c.failed.Add(ctx, 1, metric.WithAttributes(destination, attribute.String("error.type", Kind(err).String())))
+	c.onError(ctx, err)
+
+	// A failed ack means the work is done; redelivery is the lesser evil.
+	if Kind(err) == ErrKindAck {
+		return
+	}
+
+	count := receiveCount(msg)
+	span.SetAttributes(attribute.Int("messaging.receive_count", count))
+	if c.deadLetterQueueUrl == "" || (!IsPermanent(err) && count < c.maxReceiveCount) {
+		return
+	}
+
+	if err := c.deadLetter(ctx, msg); err != nil {
+		span.RecordError(err)
+		c.onError(ctx, err)
+		return
+	}
+	span.SetAttributes(attribute.Bool("messaging.dead_lettered", true))
+}
+
+func (c *Consumer[T]) deadLetter(ctx context.Context, msg types.Message) error {
+	if _, err := c.client.SendMessage(ctx, &sqs.SendMessageInput{
+		MessageAttributes: msg.MessageAttributes,
+		MessageBody:       msg.Body,
+		QueueUrl:          aws.String(c.deadLetterQueueUrl),
+	}); err != nil {
+		return &Error{Kind: ErrKindDeadLetter, MessageID: aws.ToString(msg.MessageId), Err: err}
+	}
+
+	if _, err := c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.queueUrl),
+		ReceiptHandle: msg.ReceiptHandle,
+	}); err != nil {
+		return &Error{Kind: ErrKindDeadLetter, MessageID: aws.ToString(msg.MessageId), Err: err}
+	}
+
+	return nil
+}
+
+func receiveCount(msg types.Message) int {
+	n, err := strconv.Atoi(msg.Attributes[string(types.MessageSystemAttributeNameApproximateReceiveCount)])
+	if err != nil {
+		return 1
+	}
+	return n
+}
+
+func (c *Consumer[T]) handle(ctx context.Context, msg types.Message) error {
+	var body T
+	if err := json.Unmarshal([]byte(aws.ToString(msg.Body)), &body); err != nil {
+		return &Error{Kind: ErrKindDecode, MessageID: aws.ToString(msg.MessageId), Err: Permanent(err)}
+	}
+
+	if err := c.callHandler(ctx, body); err != nil {
+		return &Error{Kind: ErrKindHandler, MessageID: aws.ToString(msg.MessageId), Err: err}
+	}
+
+	if _, err := c.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(c.queueUrl),
+		ReceiptHandle: msg.ReceiptHandle,
+	}); err != nil {
+		return &Error{Kind: ErrKindAck, MessageID: aws.ToString(msg.MessageId), Err: err}
+	}
+
+	return nil
+}
+
+// callHandler turns a panicking handler into a Permanent error, as the
+// message would panic again on every redelivery and never be dead-lettered.
+func (c *Consumer[T]) callHandler(ctx context.Context, body T) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = Permanent(fmt.Errorf("handler panicked: %v", p))
+		}
+	}()
+
+	return c.handler(ctx, body)
+}
+
+func (c *Consumer[T]) startSpan(ctx context.Context, msg types.Message) (context.Context, trace.Span) {
+	queueName := path.Base(c.queueUrl)
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(MessagingSystem),
+			semconv.MessagingOperationTypeDeliver,
+			semconv.MessagingDestinationName(queueName),
+			semconv.MessagingMessageID(aws.ToString(msg.MessageId)),
+		),
+	}
+
+	// Keep ctx for cancellation but relate the span to the producer and
+	// carry its baggage.
+	remote := NewCarrier().Extract(msg.MessageAttributes)
+	if producer := trace.SpanContextFromContext(remote); producer.IsValid() {
+		switch c.traceMode {
+		case TraceModeLink:
+			opts = append(opts, trace.WithNewRoot(), trace.WithLinks(trace.Link{SpanContext: producer}))
+		default:
+			ctx = trace.ContextWithRemoteSpanContext(ctx, producer)
+		}
+	}
+	ctx = baggage.ContextWithBaggage(ctx, baggage.FromContext(remote))
+
+	return otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s process", queueName), opts...)
+}
+
+// recordReceive records a receive span, from start until now, linked to the
+// producer of each of msgs.
+func (c *Consumer[T]) recordReceive(ctx context.Context, start time.Time, msgs []types.Message) {
+	links := make([]trace.Link, 0, len(msgs))
+	for _, msg := range msgs {
+		producer := trace.SpanContextFromContext(NewCarrier().Extract(msg.MessageAttributes))
+		if producer.IsValid() {
+			links = append(links, trace.Link{SpanContext: producer})
+		}
+	}
+
+	queueName := path.Base(c.queueUrl)
+	opts := []trace.SpanStartOption{
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithLinks(links...),
+		trace.WithAttributes(
+			semconv.MessagingSystemKey.String(MessagingSystem),
+			semconv.MessagingOperationTypeReceive,
+			semconv.MessagingDestinationName(queueName),
+			semconv.MessagingBatchMessageCount(len(msgs)),
+		),
+	}
+	_, span := otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s receive", queueName), opts...)
+	span.End()
+}
+
+type ErrKind int
+
+const (
+	// ErrKindDecode means the message body could not be unmarshalled.
+	ErrKindDecode ErrKind = iota + 1
+	// ErrKindHandler means the handler rejected the message.
+	ErrKindHandler
+	// ErrKindAck means the message was handled but could not be deleted.
+	ErrKindAck
+	// ErrKindDeadLetter means a failed message could not be dead-lettered.
+	ErrKindDeadLetter
+)
+
+func (k ErrKind) String() string {
+	switch k {
+	case ErrKindDecode:
+		return "decode"
+	case ErrKindHandler:
+		return "handler"
+	case ErrKindAck:
+		return "ack"
+	case ErrKindDeadLetter:
+		return "dead letter"
+	default:
+		return "unknown"
+	}
+}
+
+// Error describes why a message could not be processed.
+type Error struct {
+	Kind      ErrKind
+	MessageID string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("message %s: %s: %v", e.MessageID, e.Kind, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Kind reports the classification of err, or zero if err did not come from a
+// Consumer.
+func Kind(err error) ErrKind {
+	var qerr *Error
+	if errors.As(err, &qerr) {
+		return qerr.Kind
+	}
+	return 0
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent marks err as one that redelivery cannot fix, so the message is
+// dead-lettered on the first failure.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+func IsPermanent(err error) bool {
+	var perr *permanentError
+	return errors.As(err, &perr)
+}
@@ -0,0 +1,342 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	testQueueURL           = "http://queue/test-queue"
+	testDeadLetterQueueURL = "http://queue/test-dead-letter-queue"
+)
+
+// fakeClient hands out batches in order, then blocks each ReceiveMessage
+// until its context is done, as a long poll of an empty queue does.
+type fakeClient struct {
+	mu          sync.Mutex
+	batches     [][]types.Message
+	deleted     []string
+	deadLetters []string
+	released    []string
+}
+
+func (f *fakeClient) ReceiveMessage(ctx context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	f.mu.Lock()
+	if len(f.batches) > 0 {
+		batch := f.batches[0]
+		f.batches = f.batches[1:]
+		f.mu.Unlock()
+		return &sqs.ReceiveMessageOutput{Messages: batch}, nil
+	}
+	f.mu.Unlock()
+
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (f *fakeClient) DeleteMessage(_ context.Context, in *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deleted = append(f.deleted, aws.ToString(in.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func (f *fakeClient) SendMessage(_ context.Context, in *sqs.SendMessageInput, _ ...func(*sqs.Options)) (*sqs.SendMessageOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if aws.ToString(in.QueueUrl) == testDeadLetterQueueURL {
+		f.deadLetters = append(f.deadLetters, aws.ToString(in.MessageBody))
+	}
+	return &sqs.SendMessageOutput{MessageId: aws.String("dead-letter")}, nil
+}
+
+func (f *fakeClient) ChangeMessageVisibility(_ context.Context, in *sqs.ChangeMessageVisibilityInput, _ ...func(*sqs.Options)) (*sqs.ChangeMessageVisibilityOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.released = append(f.released, aws.ToString(in.ReceiptHandle))
+	return &sqs.ChangeMessageVisibilityOutput{}, nil
+}
+
+func (f *fakeClient) counts() (deleted, deadLetters, released int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.deleted), len(f.deadLetters), len(f.released)
+}
+
+type testMessage struct {
+	N int `json:"n"`
+}
+
+// newMessage returns the nth message, received receiveCount times.
+func newMessage(t *testing.T, n, receiveCount int) types.Message {
+	t.Helper()
+
+	body, err := json.Marshal(testMessage{N: n})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return types.Message{
+		MessageId:     aws.String("message-" + strconv.Itoa(n)),
+		ReceiptHandle: aws.String("receipt-" + strconv.Itoa(n)),
+		Body:          aws.String(string(body)),
+		Attributes: map[string]string{
+			string(types.MessageSystemAttributeNameApproximateReceiveCount): strconv.Itoa(receiveCount),
+		},
+	}
+}
+
+// run starts c and returns a function that stops it and waits for Run to
+// return.
+func run[T any](t *testing.T, c *Consumer[T]) (stop func()) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		select {
+		case err := <-done:
+			if !errors.Is(err, context.Canceled) {
+				t.Errorf("Run returned %v, want context.Canceled", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+	}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestConsumerAcksHandledMessages(t *testing.T) {
+	client := &fakeClient{batches: [][]types.Message{{newMessage(t, 1, 1), newMessage(t, 2, 1)}}}
+
+	var handled atomic.Int32
+	c := NewConsumer(Config{
+		Client:             client,
+		QueueURL:           testQueueURL,
+		DeadLetterQueueURL: testDeadLetterQueueURL,
+	}, func(context.Context, testMessage) error {
+		handled.Add(1)
+		return nil
+	})
+
+	stop := run(t, c)
+	waitFor(t, "acks", func() bool {
+		deleted, _, _ := client.counts()
+		return deleted == 2
+	})
+	stop()
+
+	if got := handled.Load(); got != 2 {
+		t.Errorf("handled %d messages, want 2", got)
+	}
+	if _, deadLetters, _ := client.counts(); deadLetters != 0 {
+		t.Errorf("dead-lettered %d messages, want 0", deadLetters)
+	}
+}
+
+func TestConsumerFailures(t *testing.T) {
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name               string
+		receiveCount       int
+		handler            Handler[testMessage]
+		deadLetterQueueURL string
+		wantDeadLetter     bool
+	}{
+		{
+			name:         "retried below MaxReceiveCount",
+			receiveCount: 1,
+			handler: func(context.Context, testMessage) error {
+				return errFailed
+			},
+			deadLetterQueueURL: testDeadLetterQueueURL,
+		},
+		{
+			name:         "dead-lettered at MaxReceiveCount",
+			receiveCount: 3,
+			handler: func(context.Context, testMessage) error {
+				return errFailed
+			},
+			deadLetterQueueURL: testDeadLetterQueueURL,
+			wantDeadLetter:     true,
+		},
+		{
+			name:         "dead-lettered when permanent",
+			receiveCount: 1,
+			handler: func(context.Context, testMessage) error {
+				return Permanent(errFailed)
+			},
+			deadLetterQueueURL: testDeadLetterQueueURL,
+			wantDeadLetter:     true,
+		},
+		{
+			name:         "dead-lettered when the handler panics",
+			receiveCount: 1,
+			handler: func(context.Context, testMessage) error {
+				panic("boom")
+			},
+			deadLetterQueueURL: testDeadLetterQueueURL,
+			wantDeadLetter:     true,
+		},
+		{
+			name:         "left without a dead-letter queue",
+			receiveCount: 3,
+			handler: func(context.Context, testMessage) error {
+				return Permanent(errFailed)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeClient{batches: [][]types.Message{{newMessage(t, 1, tt.receiveCount)}}}
+
+			errs := make(chan error, 1)
+			c := NewConsumer(Config{
+				Client:             client,
+				QueueURL:           testQueueURL,
+				DeadLetterQueueURL: tt.deadLetterQueueURL,
+				MaxReceiveCount:    3,
+				OnError: func(_ context.Context, err error) {
+					errs <- err
+				},
+			}, tt.handler)
+
+			stop := run(t, c)
+			var err error
+			select {
+			case err = <-errs:
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the failure")
+			}
+			if tt.wantDeadLetter {
+				waitFor(t, "dead-letter", func() bool {
+					deleted, deadLetters, _ := client.counts()
+					return deleted == 1 && deadLetters == 1
+				})
+			}
+			stop()
+
+			if Kind(err) != ErrKindHandler {
+				t.Errorf("error kind is %s, want %s", Kind(err), ErrKindHandler)
+			}
+			deleted, deadLetters, _ := client.counts()
+			if !tt.wantDeadLetter && (deleted != 0 || deadLetters != 0) {
+				t.Errorf("deleted %d and dead-lettered %d messages, want the message left for redelivery", deleted, deadLetters)
+			}
+		})
+	}
+}
+
+func TestConsumerReleasesUnhandledMessagesOnCancel(t *testing.T) {
+	client := &fakeClient{batches: [][]types.Message{{
+		newMessage(t, 1, 1),
+		newMessage(t, 2, 1),
+		newMessage(t, 3, 1),
+	}}}
+
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	c := NewConsumer(Config{
+		Client:   client,
+		QueueURL: testQueueURL,
+		Workers:  1,
+	}, func(context.Context, testMessage) error {
+		close(started)
+		<-unblock
+		return nil
+	})
+
+	stop := run(t, c)
+	<-started
+
+	// The single worker is busy, so the other two messages are still
+	// waiting to be handed over when the consumer is cancelled.
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	waitFor(t, "release", func() bool {
+		_, _, released := client.counts()
+		return released == 2
+	})
+	close(unblock)
+	<-stopped
+
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	if len(client.deleted) != 1 || client.deleted[0] != "receipt-1" {
+		t.Errorf("deleted %v, want the in-flight message to finish", client.deleted)
+	}
+	if len(client.released) != 2 || client.released[0] != "receipt-2" || client.released[1] != "receipt-3" {
+		t.Errorf("released %v, want [receipt-2 receipt-3]", client.released)
+	}
+}
+
+func TestConsumerBoundsWorkers(t *testing.T) {
+	const workers, messages = 2, 6
+
+	var batch []types.Message
+	for i := 0; i < messages; i++ {
+		batch = append(batch, newMessage(t, i, 1))
+	}
+	client := &fakeClient{batches: [][]types.Message{batch}}
+
+	var active, maxActive atomic.Int32
+	c := NewConsumer(Config{
+		Client:              client,
+		QueueURL:            testQueueURL,
+		MaxNumberOfMessages: messages,
+		Workers:             workers,
+	}, func(context.Context, testMessage) error {
+		n := active.Add(1)
+		for {
+			m := maxActive.Load()
+			if n <= m || maxActive.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		active.Add(-1)
+		return nil
+	})
+
+	stop := run(t, c)
+	waitFor(t, "acks", func() bool {
+		deleted, _, _ := client.counts()
+		return deleted == messages
+	})
+	stop()
+
+	if got := maxActive.Load(); got != workers {
+		t.Errorf("ran %d handlers at once, want %d", got, workers)
+	}
+}
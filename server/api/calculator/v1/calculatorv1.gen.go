@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -16,20 +17,94 @@ import (
 	openapi_types "github.com/oapi-codegen/runtime/types"
 )
 
+// Defines values for CalculationMode.
+const (
+	Decimal CalculationMode = "decimal"
+	Float   CalculationMode = "float"
+)
+
+// Defines values for CalculationStatus.
+const (
+	Completed CalculationStatus = "completed"
+	Failed    CalculationStatus = "failed"
+	Pending   CalculationStatus = "pending"
+)
+
+// Defines values for ErrorCode.
+const (
+	CodeDatabaseReadFailure  ErrorCode = "database_read_failure"
+	CodeDatabaseWriteFailure ErrorCode = "database_write_failure"
+	CodeInternal             ErrorCode = "internal"
+	CodeInvalidRequest       ErrorCode = "invalid_request"
+	CodeNotFound             ErrorCode = "not_found"
+)
+
+// CalculationEvent defines model for CalculationEvent.
+type CalculationEvent struct {
+	Calculation CalculationResponse `json:"calculation"`
+
+	// TraceId The trace in which the result was stored. Absent if the calculation had already finished when the stream was opened.
+	TraceId *string `json:"traceId,omitempty"`
+}
+
+// CalculationMode How an expression is evaluated. float uses double-precision floating point. decimal uses arbitrary-precision decimal arithmetic and supports +, -, *, /, parentheses, variables, abs, min, max, round, and pow with an integer exponent.
+type CalculationMode string
+
 // CalculationResponse defines model for CalculationResponse.
 type CalculationResponse struct {
-	Completed  time.Time          `json:"completed"`
-	Created    time.Time          `json:"created"`
-	Expression string             `json:"expression"`
-	Id         openapi_types.UUID `json:"id"`
-	Result     float64            `json:"result"`
-	Student    string             `json:"student"`
+	CallbackUrl *string    `json:"callbackUrl,omitempty"`
+	Completed   *time.Time `json:"completed,omitempty"`
+	Created     time.Time  `json:"created"`
+
+	// DecimalResult The exact result of a decimal mode calculation.
+	DecimalResult *string            `json:"decimalResult,omitempty"`
+	Error         *string            `json:"error,omitempty"`
+	Expression    string             `json:"expression"`
+	Id            openapi_types.UUID `json:"id"`
+
+	// Mode How an expression is evaluated. float uses double-precision floating point. decimal uses arbitrary-precision decimal arithmetic and supports +, -, *, /, parentheses, variables, abs, min, max, round, and pow with an integer exponent.
+	Mode    CalculationMode   `json:"mode"`
+	Result  *float64          `json:"result,omitempty"`
+	Status  CalculationStatus `json:"status"`
+	Student string            `json:"student"`
+
+	// Variables Values of the variables an expression refers to, by name.
+	Variables *Variables `json:"variables,omitempty"`
+}
+
+// CalculationStatus defines model for CalculationStatus.
+type CalculationStatus string
+
+// CreateCalculationBatchRequest Request bodies are limited to 8 MiB.
+type CreateCalculationBatchRequest struct {
+	Calculations []CreateCalculationRequest `json:"calculations"`
+}
+
+// CreateCalculationBatchResponse defines model for CreateCalculationBatchResponse.
+type CreateCalculationBatchResponse struct {
+	Results []CreateCalculationBatchResult `json:"results"`
+}
+
+// CreateCalculationBatchResult Exactly one of id and error is set.
+type CreateCalculationBatchResult struct {
+	Error *Error              `json:"error,omitempty"`
+	Id    *openapi_types.UUID `json:"id,omitempty"`
 }
 
 // CreateCalculationRequest defines model for CreateCalculationRequest.
 type CreateCalculationRequest struct {
+	// CallbackUrl An http or https URL that the CalculationResponse is POSTed to once the calculation completes or fails. Its host must be public: URLs that point to loopback, private or link-local addresses are rejected. The body is signed with HMAC-SHA256 in the X-Calculator-Signature header as t=<unix time>,v1=<hex digest of "<unix time>.<body>">. Failed deliveries are retried with exponential backoff.
+	CallbackUrl *string `json:"callbackUrl,omitempty"`
+
+	// Expression An arithmetic expression. It may refer to variables and call sqrt, pow, min, max, abs, round, log, log10, sin, cos, tan, asin, acos, atan and atan2.
 	Expression string `json:"expression"`
-	Student    string `json:"student"`
+
+	// Mode How an expression is evaluated. float uses double-precision floating point. decimal uses arbitrary-precision decimal arithmetic and supports +, -, *, /, parentheses, variables, abs, min, max, round, and pow with an integer exponent.
+	Mode    *CalculationMode `json:"mode,omitempty"`
+	Student string           `json:"student"`
+
+	// Variables Values of the variables an expression refers to, by name.
+	Variables *Variables `json:"variables,omitempty"`
 }
 
 // CreateCalculationResponse defines model for CreateCalculationResponse.
@@ -39,27 +114,108 @@ type CreateCalculationResponse struct {
 
 // Error defines model for Error.
 type Error struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	// Code A stable, machine-readable code.
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+}
+
+// ErrorCode A stable, machine-readable code.
+type ErrorCode string
+
+// ListCalculationsResponse defines model for ListCalculationsResponse.
+type ListCalculationsResponse struct {
+	Calculations []CalculationResponse `json:"calculations"`
+
+	// NextCursor Pass as cursor to fetch the next page. Absent on the last page.
+	NextCursor *string `json:"nextCursor,omitempty"`
 }
 
+// Variables Values of the variables an expression refers to, by name.
+type Variables map[string]float64
+
+// BadRequest defines model for BadRequest.
+type BadRequest = Error
+
 // DefaultError defines model for DefaultError.
 type DefaultError = Error
 
 // NotFound defines model for NotFound.
 type NotFound = Error
 
+// ListCalculationsParams defines parameters for ListCalculations.
+type ListCalculationsParams struct {
+	// Student Only return calculations for this student
+	Student *string `form:"student,omitempty" json:"student,omitempty"`
+
+	// Status Only return calculations with this status
+	Status *CalculationStatus `form:"status,omitempty" json:"status,omitempty"`
+
+	// CreatedAfter Only return calculations created at or after this time
+	CreatedAfter *time.Time `form:"createdAfter,omitempty" json:"createdAfter,omitempty"`
+
+	// CreatedBefore Only return calculations created before this time
+	CreatedBefore *time.Time `form:"createdBefore,omitempty" json:"createdBefore,omitempty"`
+
+	// Limit The maximum number of calculations to return
+	Limit *int32 `form:"limit,omitempty" json:"limit,omitempty"`
+
+	// Cursor The nextCursor of the previous page
+	Cursor *string `form:"cursor,omitempty" json:"cursor,omitempty"`
+}
+
+// CreateCalculationParams defines parameters for CreateCalculation.
+type CreateCalculationParams struct {
+	// Wait How long to wait for the result, such as 5s. At most 30s.
+	Wait *string `form:"wait,omitempty" json:"wait,omitempty"`
+
+	// Prefer An RFC 7240 preference. wait=5 waits up to 5 seconds for the result.
+	Prefer *string `json:"Prefer,omitempty"`
+
+	// IdempotencyKey A client-chosen key that makes retries safe. Repeating a request with the same key within the retention window returns the original calculation instead of creating a new one.
+	IdempotencyKey *string `json:"Idempotency-Key,omitempty"`
+
+	// XRequestID An identifier for the request, such as a tenant's own request or trace ID. It is carried in baggage, with the student, to every span of the calculation.
+	XRequestID *string `json:"X-Request-ID,omitempty"`
+}
+
+// StreamCalculationEventsParams defines parameters for StreamCalculationEvents.
+type StreamCalculationEventsParams struct {
+	// Student Only stream calculations for this student
+	Student *string `form:"student,omitempty" json:"student,omitempty"`
+}
+
+// CreateCalculationBatchParams defines parameters for CreateCalculationBatch.
+type CreateCalculationBatchParams struct {
+	// XRequestID An identifier for the request, such as a tenant's own request or trace ID. It is carried in baggage, with the student, to every span of the calculation.
+	XRequestID *string `json:"X-Request-ID,omitempty"`
+}
+
 // CreateCalculationJSONRequestBody defines body for CreateCalculation for application/json ContentType.
 type CreateCalculationJSONRequestBody = CreateCalculationRequest
 
+// CreateCalculationBatchJSONRequestBody defines body for CreateCalculationBatch for application/json ContentType.
+type CreateCalculationBatchJSONRequestBody = CreateCalculationBatchRequest
+
 // ServerInterface represents all server handlers.
 type ServerInterface interface {
 
+	// (GET /calculations)
+	ListCalculations(w http.ResponseWriter, r *http.Request, params ListCalculationsParams)
+
 	// (POST /calculations)
-	CreateCalculation(w http.ResponseWriter, r *http.Request)
+	CreateCalculation(w http.ResponseWriter, r *http.Request, params CreateCalculationParams)
+
+	// (GET /calculations/events)
+	StreamCalculationEvents(w http.ResponseWriter, r *http.Request, params StreamCalculationEventsParams)
 
 	// (GET /calculations/{uuid})
 	GetCalculation(w http.ResponseWriter, r *http.Request, uuid openapi_types.UUID)
+
+	// (GET /calculations/{uuid}/events)
+	StreamCalculationEvent(w http.ResponseWriter, r *http.Request, uuid openapi_types.UUID)
+
+	// (POST /calculations:batch)
+	CreateCalculationBatch(w http.ResponseWriter, r *http.Request, params CreateCalculationBatchParams)
 }
 
 // ServerInterfaceWrapper converts contexts to parameters.
@@ -71,12 +227,180 @@ type ServerInterfaceWrapper struct {
 
 type MiddlewareFunc func(http.Handler) http.Handler
 
+// ListCalculations operation middleware
+func (siw *ServerInterfaceWrapper) ListCalculations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params ListCalculationsParams
+
+	// ------------- Optional query parameter "student" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "student", r.URL.Query(), &params.Student)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "student", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "status" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "status", r.URL.Query(), &params.Status)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "status", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "createdAfter" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "createdAfter", r.URL.Query(), &params.CreatedAfter)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "createdAfter", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "createdBefore" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "createdBefore", r.URL.Query(), &params.CreatedBefore)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "createdBefore", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "limit" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "limit", r.URL.Query(), &params.Limit)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "limit", Err: err})
+		return
+	}
+
+	// ------------- Optional query parameter "cursor" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "cursor", r.URL.Query(), &params.Cursor)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "cursor", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.ListCalculations(w, r, params)
+	}))
+
+	for i := len(siw.HandlerMiddlewares) - 1; i >= 0; i-- {
+		handler = siw.HandlerMiddlewares[i](handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
 // CreateCalculation operation middleware
 func (siw *ServerInterfaceWrapper) CreateCalculation(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreateCalculationParams
+
+	// ------------- Optional query parameter "wait" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "wait", r.URL.Query(), &params.Wait)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "wait", Err: err})
+		return
+	}
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "Prefer" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Prefer")]; found {
+		var Prefer string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Prefer", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithLocation("simple", false, "Prefer", runtime.ParamLocationHeader, valueList[0], &Prefer)
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Prefer", Err: err})
+			return
+		}
+
+		params.Prefer = &Prefer
+
+	}
+
+	// ------------- Optional header parameter "Idempotency-Key" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("Idempotency-Key")]; found {
+		var IdempotencyKey string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "Idempotency-Key", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithLocation("simple", false, "Idempotency-Key", runtime.ParamLocationHeader, valueList[0], &IdempotencyKey)
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "Idempotency-Key", Err: err})
+			return
+		}
+
+		params.IdempotencyKey = &IdempotencyKey
+
+	}
+
+	// ------------- Optional header parameter "X-Request-ID" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Request-ID")]; found {
+		var XRequestID string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "X-Request-ID", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithLocation("simple", false, "X-Request-ID", runtime.ParamLocationHeader, valueList[0], &XRequestID)
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "X-Request-ID", Err: err})
+			return
+		}
+
+		params.XRequestID = &XRequestID
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateCalculation(w, r, params)
+	}))
+
+	for i := len(siw.HandlerMiddlewares) - 1; i >= 0; i-- {
+		handler = siw.HandlerMiddlewares[i](handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// StreamCalculationEvents operation middleware
+func (siw *ServerInterfaceWrapper) StreamCalculationEvents(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params StreamCalculationEventsParams
+
+	// ------------- Optional query parameter "student" -------------
+
+	err = runtime.BindQueryParameter("form", true, false, "student", r.URL.Query(), &params.Student)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "student", Err: err})
+		return
+	}
+
 	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		siw.Handler.CreateCalculation(w, r)
+		siw.Handler.StreamCalculationEvents(w, r, params)
 	}))
 
 	for i := len(siw.HandlerMiddlewares) - 1; i >= 0; i-- {
@@ -112,6 +436,73 @@ func (siw *ServerInterfaceWrapper) GetCalculation(w http.ResponseWriter, r *http
 	handler.ServeHTTP(w, r.WithContext(ctx))
 }
 
+// StreamCalculationEvent operation middleware
+func (siw *ServerInterfaceWrapper) StreamCalculationEvent(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// ------------- Path parameter "uuid" -------------
+	var uuid openapi_types.UUID
+
+	err = runtime.BindStyledParameter("simple", false, "uuid", mux.Vars(r)["uuid"], &uuid)
+	if err != nil {
+		siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "uuid", Err: err})
+		return
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.StreamCalculationEvent(w, r, uuid)
+	}))
+
+	for i := len(siw.HandlerMiddlewares) - 1; i >= 0; i-- {
+		handler = siw.HandlerMiddlewares[i](handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// CreateCalculationBatch operation middleware
+func (siw *ServerInterfaceWrapper) CreateCalculationBatch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	var err error
+
+	// Parameter object where we will unmarshal all parameters from the context
+	var params CreateCalculationBatchParams
+
+	headers := r.Header
+
+	// ------------- Optional header parameter "X-Request-ID" -------------
+	if valueList, found := headers[http.CanonicalHeaderKey("X-Request-ID")]; found {
+		var XRequestID string
+		n := len(valueList)
+		if n != 1 {
+			siw.ErrorHandlerFunc(w, r, &TooManyValuesForParamError{ParamName: "X-Request-ID", Count: n})
+			return
+		}
+
+		err = runtime.BindStyledParameterWithLocation("simple", false, "X-Request-ID", runtime.ParamLocationHeader, valueList[0], &XRequestID)
+		if err != nil {
+			siw.ErrorHandlerFunc(w, r, &InvalidParamFormatError{ParamName: "X-Request-ID", Err: err})
+			return
+		}
+
+		params.XRequestID = &XRequestID
+
+	}
+
+	handler := http.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		siw.Handler.CreateCalculationBatch(w, r, params)
+	}))
+
+	for i := len(siw.HandlerMiddlewares) - 1; i >= 0; i-- {
+		handler = siw.HandlerMiddlewares[i](handler)
+	}
+
+	handler.ServeHTTP(w, r.WithContext(ctx))
+}
+
 type UnescapedCookieParamError struct {
 	ParamName string
 	Err       error
@@ -225,26 +616,75 @@ func HandlerWithOptions(si ServerInterface, options GorillaServerOptions) http.H
 		ErrorHandlerFunc:   options.ErrorHandlerFunc,
 	}
 
+	r.HandleFunc(options.BaseURL+"/calculations", wrapper.ListCalculations).Methods("GET")
+
 	r.HandleFunc(options.BaseURL+"/calculations", wrapper.CreateCalculation).Methods("POST")
 
+	r.HandleFunc(options.BaseURL+"/calculations/events", wrapper.StreamCalculationEvents).Methods("GET")
+
 	r.HandleFunc(options.BaseURL+"/calculations/{uuid}", wrapper.GetCalculation).Methods("GET")
 
+	r.HandleFunc(options.BaseURL+"/calculations/{uuid}/events", wrapper.StreamCalculationEvent).Methods("GET")
+
+	r.HandleFunc(options.BaseURL+"/calculations:batch", wrapper.CreateCalculationBatch).Methods("POST")
+
 	return r
 }
 
+type BadRequestJSONResponse Error
+
 type DefaultErrorJSONResponse Error
 
 type NotFoundJSONResponse Error
 
+type ListCalculationsRequestObject struct {
+	Params ListCalculationsParams
+}
+
+type ListCalculationsResponseObject interface {
+	VisitListCalculationsResponse(w http.ResponseWriter) error
+}
+
+type ListCalculations200JSONResponse ListCalculationsResponse
+
+func (response ListCalculations200JSONResponse) VisitListCalculationsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ListCalculations400JSONResponse struct{ BadRequestJSONResponse }
+
+func (response ListCalculations400JSONResponse) VisitListCalculationsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type ListCalculationsdefaultJSONResponse struct {
+	Body       Error
+	StatusCode int
+}
+
+func (response ListCalculationsdefaultJSONResponse) VisitListCalculationsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
 type CreateCalculationRequestObject struct {
-	Body *CreateCalculationJSONRequestBody
+	Params CreateCalculationParams
+	Body   *CreateCalculationJSONRequestBody
 }
 
 type CreateCalculationResponseObject interface {
 	VisitCreateCalculationResponse(w http.ResponseWriter) error
 }
 
-type CreateCalculation200JSONResponse CreateCalculationResponse
+type CreateCalculation200JSONResponse CalculationResponse
 
 func (response CreateCalculation200JSONResponse) VisitCreateCalculationResponse(w http.ResponseWriter) error {
 	w.Header().Set("Content-Type", "application/json")
@@ -253,6 +693,24 @@ func (response CreateCalculation200JSONResponse) VisitCreateCalculationResponse(
 	return json.NewEncoder(w).Encode(response)
 }
 
+type CreateCalculation202JSONResponse CreateCalculationResponse
+
+func (response CreateCalculation202JSONResponse) VisitCreateCalculationResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(202)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type CreateCalculation400JSONResponse struct{ BadRequestJSONResponse }
+
+func (response CreateCalculation400JSONResponse) VisitCreateCalculationResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
 type CreateCalculationdefaultJSONResponse struct {
 	Body       Error
 	StatusCode int
@@ -265,6 +723,45 @@ func (response CreateCalculationdefaultJSONResponse) VisitCreateCalculationRespo
 	return json.NewEncoder(w).Encode(response.Body)
 }
 
+type StreamCalculationEventsRequestObject struct {
+	Params StreamCalculationEventsParams
+}
+
+type StreamCalculationEventsResponseObject interface {
+	VisitStreamCalculationEventsResponse(w http.ResponseWriter) error
+}
+
+type StreamCalculationEvents200TexteventStreamResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response StreamCalculationEvents200TexteventStreamResponse) VisitStreamCalculationEventsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type StreamCalculationEventsdefaultJSONResponse struct {
+	Body       Error
+	StatusCode int
+}
+
+func (response StreamCalculationEventsdefaultJSONResponse) VisitStreamCalculationEventsResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
 type GetCalculationRequestObject struct {
 	Uuid openapi_types.UUID `json:"uuid"`
 }
@@ -303,14 +800,113 @@ func (response GetCalculationdefaultJSONResponse) VisitGetCalculationResponse(w
 	return json.NewEncoder(w).Encode(response.Body)
 }
 
+type StreamCalculationEventRequestObject struct {
+	Uuid openapi_types.UUID `json:"uuid"`
+}
+
+type StreamCalculationEventResponseObject interface {
+	VisitStreamCalculationEventResponse(w http.ResponseWriter) error
+}
+
+type StreamCalculationEvent200TexteventStreamResponse struct {
+	Body          io.Reader
+	ContentLength int64
+}
+
+func (response StreamCalculationEvent200TexteventStreamResponse) VisitStreamCalculationEventResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "text/event-stream")
+	if response.ContentLength != 0 {
+		w.Header().Set("Content-Length", fmt.Sprint(response.ContentLength))
+	}
+	w.WriteHeader(200)
+
+	if closer, ok := response.Body.(io.ReadCloser); ok {
+		defer closer.Close()
+	}
+	_, err := io.Copy(w, response.Body)
+	return err
+}
+
+type StreamCalculationEvent404JSONResponse struct{ NotFoundJSONResponse }
+
+func (response StreamCalculationEvent404JSONResponse) VisitStreamCalculationEventResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(404)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type StreamCalculationEventdefaultJSONResponse struct {
+	Body       Error
+	StatusCode int
+}
+
+func (response StreamCalculationEventdefaultJSONResponse) VisitStreamCalculationEventResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
+type CreateCalculationBatchRequestObject struct {
+	Params CreateCalculationBatchParams
+	Body   *CreateCalculationBatchJSONRequestBody
+}
+
+type CreateCalculationBatchResponseObject interface {
+	VisitCreateCalculationBatchResponse(w http.ResponseWriter) error
+}
+
+type CreateCalculationBatch200JSONResponse CreateCalculationBatchResponse
+
+func (response CreateCalculationBatch200JSONResponse) VisitCreateCalculationBatchResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type CreateCalculationBatch400JSONResponse struct{ BadRequestJSONResponse }
+
+func (response CreateCalculationBatch400JSONResponse) VisitCreateCalculationBatchResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(400)
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+type CreateCalculationBatchdefaultJSONResponse struct {
+	Body       Error
+	StatusCode int
+}
+
+func (response CreateCalculationBatchdefaultJSONResponse) VisitCreateCalculationBatchResponse(w http.ResponseWriter) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(response.StatusCode)
+
+	return json.NewEncoder(w).Encode(response.Body)
+}
+
 // StrictServerInterface represents all server handlers.
 type StrictServerInterface interface {
 
+	// (GET /calculations)
+	ListCalculations(ctx context.Context, request ListCalculationsRequestObject) (ListCalculationsResponseObject, error)
+
 	// (POST /calculations)
 	CreateCalculation(ctx context.Context, request CreateCalculationRequestObject) (CreateCalculationResponseObject, error)
 
+	// (GET /calculations/events)
+	StreamCalculationEvents(ctx context.Context, request StreamCalculationEventsRequestObject) (StreamCalculationEventsResponseObject, error)
+
 	// (GET /calculations/{uuid})
 	GetCalculation(ctx context.Context, request GetCalculationRequestObject) (GetCalculationResponseObject, error)
+
+	// (GET /calculations/{uuid}/events)
+	StreamCalculationEvent(ctx context.Context, request StreamCalculationEventRequestObject) (StreamCalculationEventResponseObject, error)
+
+	// (POST /calculations:batch)
+	CreateCalculationBatch(ctx context.Context, request CreateCalculationBatchRequestObject) (CreateCalculationBatchResponseObject, error)
 }
 
 type StrictHandlerFunc = strictnethttp.StrictHttpHandlerFunc
@@ -342,10 +938,38 @@ type strictHandler struct {
 	options     StrictHTTPServerOptions
 }
 
+// ListCalculations operation middleware
+func (sh *strictHandler) ListCalculations(w http.ResponseWriter, r *http.Request, params ListCalculationsParams) {
+	var request ListCalculationsRequestObject
+
+	request.Params = params
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.ListCalculations(ctx, request.(ListCalculationsRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "ListCalculations")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(ListCalculationsResponseObject); ok {
+		if err := validResponse.VisitListCalculationsResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
 // CreateCalculation operation middleware
-func (sh *strictHandler) CreateCalculation(w http.ResponseWriter, r *http.Request) {
+func (sh *strictHandler) CreateCalculation(w http.ResponseWriter, r *http.Request, params CreateCalculationParams) {
 	var request CreateCalculationRequestObject
 
+	request.Params = params
+
 	var body CreateCalculationJSONRequestBody
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		sh.options.RequestErrorHandlerFunc(w, r, fmt.Errorf("can't decode JSON body: %w", err))
@@ -373,6 +997,32 @@ func (sh *strictHandler) CreateCalculation(w http.ResponseWriter, r *http.Reques
 	}
 }
 
+// StreamCalculationEvents operation middleware
+func (sh *strictHandler) StreamCalculationEvents(w http.ResponseWriter, r *http.Request, params StreamCalculationEventsParams) {
+	var request StreamCalculationEventsRequestObject
+
+	request.Params = params
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.StreamCalculationEvents(ctx, request.(StreamCalculationEventsRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "StreamCalculationEvents")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(StreamCalculationEventsResponseObject); ok {
+		if err := validResponse.VisitStreamCalculationEventsResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
 // GetCalculation operation middleware
 func (sh *strictHandler) GetCalculation(w http.ResponseWriter, r *http.Request, uuid openapi_types.UUID) {
 	var request GetCalculationRequestObject
@@ -398,3 +1048,62 @@ func (sh *strictHandler) GetCalculation(w http.ResponseWriter, r *http.Request,
 		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
 	}
 }
+
+// StreamCalculationEvent operation middleware
+func (sh *strictHandler) StreamCalculationEvent(w http.ResponseWriter, r *http.Request, uuid openapi_types.UUID) {
+	var request StreamCalculationEventRequestObject
+
+	request.Uuid = uuid
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.StreamCalculationEvent(ctx, request.(StreamCalculationEventRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "StreamCalculationEvent")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(StreamCalculationEventResponseObject); ok {
+		if err := validResponse.VisitStreamCalculationEventResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
+
+// CreateCalculationBatch operation middleware
+func (sh *strictHandler) CreateCalculationBatch(w http.ResponseWriter, r *http.Request, params CreateCalculationBatchParams) {
+	var request CreateCalculationBatchRequestObject
+
+	request.Params = params
+
+	var body CreateCalculationBatchJSONRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		sh.options.RequestErrorHandlerFunc(w, r, fmt.Errorf("can't decode JSON body: %w", err))
+		return
+	}
+	request.Body = &body
+
+	handler := func(ctx context.Context, w http.ResponseWriter, r *http.Request, request interface{}) (interface{}, error) {
+		return sh.ssi.CreateCalculationBatch(ctx, request.(CreateCalculationBatchRequestObject))
+	}
+	for _, middleware := range sh.middlewares {
+		handler = middleware(handler, "CreateCalculationBatch")
+	}
+
+	response, err := handler(r.Context(), w, r, request)
+
+	if err != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, err)
+	} else if validResponse, ok := response.(CreateCalculationBatchResponseObject); ok {
+		if err := validResponse.VisitCreateCalculationBatchResponse(w); err != nil {
+			sh.options.ResponseErrorHandlerFunc(w, r, err)
+		}
+	} else if response != nil {
+		sh.options.ResponseErrorHandlerFunc(w, r, fmt.Errorf("unexpected response type: %T", response))
+	}
+}
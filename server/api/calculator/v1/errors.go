@@ -0,0 +1,30 @@
+package calculatorv1
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+func writeError(w http.ResponseWriter, statusCode int, body Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func requestErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	statusCode := http.StatusBadRequest
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		statusCode = http.StatusRequestEntityTooLarge
+	}
+	writeError(w, statusCode, Error{Code: CodeInvalidRequest, Message: err.Error()})
+}
+
+// responseErrorHandler logs err rather than returning it, as it may describe
+// internals such as a failed query.
+func responseErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	slog.ErrorContext(r.Context(), "Unable to handle request", "error", err)
+	writeError(w, http.StatusInternalServerError, Error{Code: CodeInternal, Message: "internal error"})
+}
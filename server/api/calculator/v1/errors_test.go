@@ -0,0 +1,27 @@
+package calculatorv1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseErrorHandlerHidesError(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/calculations", nil)
+
+	responseErrorHandler(w, r, errors.New(`pq: relation "calculations" does not exist`))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status is %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	var body Error
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Error{Code: CodeInternal, Message: "internal error"}); body != want {
+		t.Errorf("body is %+v, want %+v", body, want)
+	}
+}
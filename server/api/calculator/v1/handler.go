@@ -5,10 +5,35 @@ import (
 
 	"github.com/gorilla/mux"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gorilla/mux/otelmux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func MakeHTTPHandler(si StrictServerInterface) http.Handler {
 	mux := mux.NewRouter()
 	mux.Use(otelmux.Middleware("otel-test"))
-	return HandlerFromMux(NewStrictHandler(si, nil), mux)
+	mux.Use(limitRequestBody)
+	// otelmux only traces; otelhttp adds the http.server.* metrics. Its spans
+	// are disabled so requests aren't traced twice.
+	handler := HandlerWithOptions(NewStrictHandlerWithOptions(si, nil, StrictHTTPServerOptions{
+		RequestErrorHandlerFunc:  requestErrorHandler,
+		ResponseErrorHandlerFunc: responseErrorHandler,
+	}), GorillaServerOptions{
+		BaseRouter:       mux,
+		ErrorHandlerFunc: requestErrorHandler,
+	})
+	return otelhttp.NewHandler(handler, "otel-test",
+		otelhttp.WithTracerProvider(noop.NewTracerProvider()),
+	)
+}
+
+// maxRequestBodySize fits a full batch of the largest calculations the spec
+// allows.
+const maxRequestBodySize = 8 << 20
+
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		next.ServeHTTP(w, r)
+	})
 }
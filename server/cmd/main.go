@@ -2,62 +2,245 @@ package main
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
 	api "github.com/MukeshGKastala/nola-otel-demo/server/api/calculator/v1"
+	"github.com/MukeshGKastala/nola-otel-demo/server/events"
 	"github.com/MukeshGKastala/nola-otel-demo/server/math"
+	"github.com/MukeshGKastala/nola-otel-demo/server/outbox"
 	"github.com/MukeshGKastala/nola-otel-demo/server/service"
 	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
+	"github.com/MukeshGKastala/nola-otel-demo/server/webhook"
 )
 
 func main() {
-	ctx := context.Background()
+	if err := run(); err != nil {
+		slog.Error("Exiting", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTimeout := 10 * time.Second
+	if v := os.Getenv("SHUTDOWN_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		shutdownTimeout = d
+	}
+
+	// Cleanups run in reverse order and share a single deadline.
+	var cleanups []func(context.Context)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i](ctx)
+		}
+	}()
+
+	// Register global logger provider first so it is flushed last.
+	lp, err := otelcommon.InitLogger(ctx, otelcommon.Config{
+		ServiceName:    "server",
+		ServiceVersion: "v0.0.1",
+	})
+	if err != nil {
+		return err
+	}
+	cleanups = append(cleanups, func(ctx context.Context) {
+		if err := lp.Shutdown(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error shutting down logger provider", "error", err)
+		}
+	})
 
 	// Register global trace provider.
 	tp, err := otelcommon.InitTracer(ctx, otelcommon.Config{
 		ServiceName:    "server",
 		ServiceVersion: "v0.0.1",
+		BaggageKeys:    []string{otelcommon.BaggageStudent, otelcommon.BaggageRequestID},
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer func() {
+	cleanups = append(cleanups, func(ctx context.Context) {
 		if err := tp.Shutdown(ctx); err != nil {
-			log.Printf("Error shutting down tracer provider: %v", err)
+			slog.ErrorContext(ctx, "Error shutting down tracer provider", "error", err)
 		}
-	}()
+	})
 
-	conn, err := postgres.ConnectAndMigrate(ctx, postgres.Config{
-		Host:         os.Getenv("POSTGRES_HOST"),
-		User:         os.Getenv("POSTGRES_USER"),
-		Password:     os.Getenv("POSTGRES_PASSWORD"),
-		DatabaseName: os.Getenv("POSTGRES_DB"),
+	// Register global meter provider.
+	mp, err := otelcommon.InitMeter(ctx, otelcommon.Config{
+		ServiceName:    "server",
+		ServiceVersion: "v0.0.1",
 	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	defer conn.Close(ctx)
+	cleanups = append(cleanups, func(ctx context.Context) {
+		if err := mp.Shutdown(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error shutting down meter provider", "error", err)
+		}
+	})
 
-	store := postgres.New(conn)
+	maxConns, _ := strconv.Atoi(os.Getenv("POSTGRES_MAX_CONNS"))
+	minConns, _ := strconv.Atoi(os.Getenv("POSTGRES_MIN_CONNS"))
+	maxConnLifetime, _ := time.ParseDuration(os.Getenv("POSTGRES_MAX_CONN_LIFETIME"))
+	maxConnIdleTime, _ := time.ParseDuration(os.Getenv("POSTGRES_MAX_CONN_IDLE_TIME"))
 
-	calculator, err := math.New(ctx, math.Config{
-		SQSRegion:         os.Getenv("SQS_REGION"),
-		SQSBaseEndpoint:   os.Getenv("SQS_BASE_ENDPOINT"),
-		SQSReadQueueName:  os.Getenv("SQS_READ_QUEUE_NAME"),
-		SQSWriteQueueName: os.Getenv("SQS_WRITE_QUEUE_NAME"),
+	pool, err := postgres.ConnectAndMigrate(ctx, postgres.Config{
+		Host:            os.Getenv("POSTGRES_HOST"),
+		User:            os.Getenv("POSTGRES_USER"),
+		Password:        os.Getenv("POSTGRES_PASSWORD"),
+		DatabaseName:    os.Getenv("POSTGRES_DB"),
+		MaxConns:        int32(maxConns),
+		MinConns:        int32(minConns),
+		MaxConnLifetime: maxConnLifetime,
+		MaxConnIdleTime: maxConnIdleTime,
+	})
+	if err != nil {
+		return err
+	}
+	cleanups = append(cleanups, func(context.Context) {
+		pool.Close()
+	})
+
+	store := postgres.NewStore(pool)
+	broker := events.NewBroker()
+
+	webhookMaxAttempts, _ := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	webhookInitialBackoff, _ := time.ParseDuration(os.Getenv("WEBHOOK_INITIAL_BACKOFF"))
+	webhookMaxBackoff, _ := time.ParseDuration(os.Getenv("WEBHOOK_MAX_BACKOFF"))
+	webhookTimeout, _ := time.ParseDuration(os.Getenv("WEBHOOK_TIMEOUT"))
+
+	dispatcher, err := webhook.NewDispatcher(webhook.Config{
+		Secret:         os.Getenv("WEBHOOK_SECRET"),
+		MaxAttempts:    webhookMaxAttempts,
+		InitialBackoff: webhookInitialBackoff,
+		MaxBackoff:     webhookMaxBackoff,
+		Timeout:        webhookTimeout,
 	}, store)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	cleanups = append(cleanups, dispatcher.Close)
+
+	maxReceiveCount, _ := strconv.Atoi(os.Getenv("SQS_MAX_RECEIVE_COUNT"))
+
+	calculator, err := math.New(ctx, math.Config{
+		SQSRegion:              os.Getenv("SQS_REGION"),
+		SQSBaseEndpoint:        os.Getenv("SQS_BASE_ENDPOINT"),
+		SQSReadQueueName:       os.Getenv("SQS_READ_QUEUE_NAME"),
+		SQSWriteQueueName:      os.Getenv("SQS_WRITE_QUEUE_NAME"),
+		SQSDeadLetterQueueName: os.Getenv("SQS_DEAD_LETTER_QUEUE_NAME"),
+		SQSMaxReceiveCount:     maxReceiveCount,
+		SQSTraceMode:           os.Getenv("SQS_TRACE_MODE"),
+	}, store, broker, webhookNotifier{dispatcher})
+	if err != nil {
+		return err
 	}
 
-	svc := service.NewService(store, calculator)
+	consumerDone := make(chan struct{})
+	go func() {
+		defer close(consumerDone)
+		if err := calculator.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Unable to receive queue messages", "error", err)
+		}
+	}()
+	cleanups = append(cleanups, func(ctx context.Context) {
+		select {
+		case <-consumerDone:
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "Timed out draining queue messages")
+		}
+	})
+
+	pollInterval, _ := time.ParseDuration(os.Getenv("OUTBOX_POLL_INTERVAL"))
+	batchSize, _ := strconv.Atoi(os.Getenv("OUTBOX_BATCH_SIZE"))
+	outboxMaxAttempts, _ := strconv.Atoi(os.Getenv("OUTBOX_MAX_ATTEMPTS"))
+	outboxInitialBackoff, _ := time.ParseDuration(os.Getenv("OUTBOX_INITIAL_BACKOFF"))
+	outboxMaxBackoff, _ := time.ParseDuration(os.Getenv("OUTBOX_MAX_BACKOFF"))
+	outboxRetention, _ := time.ParseDuration(os.Getenv("OUTBOX_RETENTION"))
+
+	relay := outbox.NewRelay(outbox.Config{
+		PollInterval:   pollInterval,
+		BatchSize:      batchSize,
+		MaxAttempts:    outboxMaxAttempts,
+		InitialBackoff: outboxInitialBackoff,
+		MaxBackoff:     outboxMaxBackoff,
+		Retention:      outboxRetention,
+	}, store, calculator)
+
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		if err := relay.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			slog.ErrorContext(ctx, "Unable to relay outbox messages", "error", err)
+		}
+	}()
+	cleanups = append(cleanups, func(ctx context.Context) {
+		select {
+		case <-relayDone:
+		case <-ctx.Done():
+			slog.WarnContext(ctx, "Timed out draining outbox messages")
+		}
+	})
+
+	svc := service.NewService(store, relay, broker)
 
 	server := &http.Server{
 		Handler: api.MakeHTTPHandler(svc),
 	}
+	// End event streams, which would otherwise hold Shutdown until the
+	// deadline.
+	server.RegisterOnShutdown(broker.Close)
+	cleanups = append(cleanups, func(ctx context.Context) {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.ErrorContext(ctx, "Error shutting down http server", "error", err)
+		}
+	})
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
 
-	log.Fatal(server.ListenAndServe())
+	select {
+	case err := <-serverErr:
+		stop()
+		return err
+	case <-ctx.Done():
+		// Restore the default signal handling, so that a second signal
+		// exits without waiting for the cleanups.
+		stop()
+		slog.InfoContext(ctx, "Shutting down")
+		return nil
+	}
+}
+
+// webhookNotifier sends each result to its callback URL in the same shape the
+// API returns it.
+type webhookNotifier struct {
+	dispatcher *webhook.Dispatcher
+}
+
+func (n webhookNotifier) Publish(ctx context.Context, calc postgres.Calculation) {
+	body, err := json.Marshal(service.NewCalculationResponse(calc))
+	if err != nil {
+		slog.ErrorContext(ctx, "Unable to encode webhook body", "error", err, "id", calc.ID)
+		return
+	}
+	n.dispatcher.Publish(ctx, calc, body)
 }
@@ -0,0 +1,57 @@
+// Package egress decides which hosts the server may connect to on a caller's
+// behalf, such as to deliver a callback.
+package egress
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+	"syscall"
+)
+
+var (
+	// ErrInternalAddress is returned for loopback, private, link-local,
+	// multicast and unspecified addresses.
+	ErrInternalAddress = errors.New("internal address")
+	// ErrInternalHost is returned for hostnames that only resolve on our
+	// own network.
+	ErrInternalHost = errors.New("internal hostname")
+)
+
+// PublicAddr reports whether addr is a public unicast address.
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate()
+}
+
+// CheckHost returns ErrInternalAddress or ErrInternalHost if host, the host
+// of a URL, must not be connected to. Names without a dot, such as db or
+// queue, resolve to services on our own network. A hostname that passes may
+// still resolve to an internal address; Control refuses those.
+func CheckHost(host string) error {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !PublicAddr(addr) {
+			return ErrInternalAddress
+		}
+		return nil
+	}
+	if !strings.Contains(host, ".") || strings.HasSuffix(host, ".localhost") {
+		return ErrInternalHost
+	}
+	return nil
+}
+
+// Control is a net.Dialer Control function that refuses to connect to
+// addresses that aren't public, whatever name they were resolved from.
+func Control(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !PublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w %s", ErrInternalAddress, addrPort.Addr())
+	}
+	return nil
+}
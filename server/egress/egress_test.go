@@ -0,0 +1,67 @@
+package egress
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want error
+	}{
+		{host: "example.com", want: nil},
+		{host: "Example.COM.", want: nil},
+		{host: "93.184.216.34", want: nil},
+		{host: "2606:2800:220:1:248:1893:25c8:1946", want: nil},
+		{host: "127.0.0.1", want: ErrInternalAddress},
+		{host: "::1", want: ErrInternalAddress},
+		{host: "10.0.0.1", want: ErrInternalAddress},
+		{host: "172.16.0.1", want: ErrInternalAddress},
+		{host: "192.168.1.1", want: ErrInternalAddress},
+		{host: "fd00::1", want: ErrInternalAddress},
+		{host: "169.254.169.254", want: ErrInternalAddress},
+		{host: "fe80::1", want: ErrInternalAddress},
+		{host: "0.0.0.0", want: ErrInternalAddress},
+		{host: "::ffff:127.0.0.1", want: ErrInternalAddress},
+		{host: "::ffff:10.0.0.1", want: ErrInternalAddress},
+		{host: "::ffff:169.254.169.254", want: ErrInternalAddress},
+		{host: "localhost", want: ErrInternalHost},
+		{host: "db", want: ErrInternalHost},
+		{host: "queue.", want: ErrInternalHost},
+		{host: "api.localhost", want: ErrInternalHost},
+		{host: "API.LOCALHOST.", want: ErrInternalHost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if err := CheckHost(tt.host); !errors.Is(err, tt.want) {
+				t.Errorf("CheckHost(%q) = %v, want %v", tt.host, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestControl(t *testing.T) {
+	tests := []struct {
+		address string
+		want    error
+	}{
+		{address: "93.184.216.34:443", want: nil},
+		{address: "[2606:2800:220:1:248:1893:25c8:1946]:443", want: nil},
+		{address: "127.0.0.1:80", want: ErrInternalAddress},
+		{address: "[::1]:80", want: ErrInternalAddress},
+		{address: "10.1.2.3:8080", want: ErrInternalAddress},
+		{address: "169.254.169.254:80", want: ErrInternalAddress},
+		{address: "[fe80::1]:80", want: ErrInternalAddress},
+		{address: "[::ffff:192.168.0.1]:80", want: ErrInternalAddress},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if err := Control("tcp", tt.address, nil); !errors.Is(err, tt.want) {
+				t.Errorf("Control(%q) = %v, want %v", tt.address, err, tt.want)
+			}
+		})
+	}
+}
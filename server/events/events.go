@@ -0,0 +1,101 @@
+package events
+
+import (
+	"context"
+	"sync"
+
+	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Event reports that a calculation finished.
+type Event struct {
+	Calculation postgres.Calculation
+	// TraceID identifies the trace in which the result was stored.
+	TraceID string
+}
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before further ones are dropped for it.
+const subscriberBuffer = 16
+
+type subscriber struct {
+	match func(postgres.Calculation) bool
+	ch    chan Event
+}
+
+// Broker fans out finished calculations to in-process subscribers. It only
+// sees results applied by this server instance.
+type Broker struct {
+	mu          sync.Mutex
+	subscribers map[*subscriber]struct{}
+	closed      bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subscribers: map[*subscriber]struct{}{}}
+}
+
+// Subscribe returns a channel that receives an Event for each published
+// calculation for which match returns true. Call cancel to unsubscribe. The
+// channel is closed when the Broker is.
+func (b *Broker) Subscribe(match func(postgres.Calculation) bool) (ch <-chan Event, cancel func()) {
+	s := &subscriber{
+		match: match,
+		ch:    make(chan Event, subscriberBuffer),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(s.ch)
+		return s.ch, func() {}
+	}
+	b.subscribers[s] = struct{}{}
+
+	return s.ch, func() {
+		b.mu.Lock()
+		delete(b.subscribers, s)
+		b.mu.Unlock()
+	}
+}
+
+// Publish delivers calc, with the trace of ctx, to matching subscribers
+// without blocking.
+func (b *Broker) Publish(ctx context.Context, calc postgres.Calculation) {
+	event := Event{Calculation: calc}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		event.TraceID = sc.TraceID().String()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for s := range b.subscribers {
+		if !s.match(calc) {
+			continue
+		}
+		select {
+		case s.ch <- event:
+		default:
+		}
+	}
+}
+
+// Close closes all subscriber channels, ending long-lived streams so the
+// HTTP server can shut down.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	for s := range b.subscribers {
+		close(s.ch)
+		delete(b.subscribers, s)
+	}
+}
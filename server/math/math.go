@@ -3,21 +3,24 @@ package math
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"path"
+	"strconv"
 	"time"
 
 	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
+	"github.com/MukeshGKastala/nola-otel-demo/common/queue"
 	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
-	"github.com/udhos/opentelemetry-trace-sqs/otelsqs"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/metric"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -25,27 +28,41 @@ type Store interface {
 	UpdateCalculation(context.Context, postgres.UpdateCalculationParams) (postgres.Calculation, error)
 }
 
+type Notifier interface {
+	Publish(context.Context, postgres.Calculation)
+}
+
 type Calculation struct {
-	ID         uuid.UUID `json:"id"`
-	Student    string    `json:"student"`
-	Expression string    `json:"expression"`
+	ID         uuid.UUID          `json:"id"`
+	Student    string             `json:"student"`
+	Expression string             `json:"expression"`
+	Variables  map[string]float64 `json:"variables,omitempty"`
+	Mode       string             `json:"mode"`
 }
 
 type Config struct {
-	SQSRegion         string
-	SQSBaseEndpoint   string
-	SQSReadQueueName  string
-	SQSWriteQueueName string
+	SQSRegion              string
+	SQSBaseEndpoint        string
+	SQSReadQueueName       string
+	SQSWriteQueueName      string
+	SQSDeadLetterQueueName string
+	SQSMaxReceiveCount     int
+	SQSTraceMode           string
 }
 
 type handler struct {
 	client        *sqs.Client
-	readQueueUrl  string
 	writeQueueUrl string
 	store         Store
+	notifiers     []Notifier
+	consumer      *queue.Consumer[result]
+	latency       metric.Float64Histogram
+	duplicates    metric.Int64Counter
 }
 
-func New(ctx context.Context, cfg Config, store Store) (*handler, error) {
+// New creates a handler that tells each of notifiers about every result it
+// applies.
+func New(ctx context.Context, cfg Config, store Store, notifiers ...Notifier) (*handler, error) {
 	c := sqs.New(sqs.Options{
 		Region:       cfg.SQSRegion,
 		BaseEndpoint: aws.String(cfg.SQSBaseEndpoint),
@@ -69,122 +86,228 @@ func New(ctx context.Context, cfg Config, store Store) (*handler, error) {
 
 	writeQueueUrl := *resp.QueueUrl
 
+	var deadLetterQueueUrl string
+	if cfg.SQSDeadLetterQueueName != "" {
+		resp, err = c.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{
+			QueueName: aws.String(cfg.SQSDeadLetterQueueName),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		deadLetterQueueUrl = *resp.QueueUrl
+	}
+
+	latency, err := otelcommon.Meter().Float64Histogram("calculation.duration",
+		metric.WithDescription("The time from a calculation being created to its result being stored."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+
+	duplicates, err := otelcommon.Meter().Int64Counter("calculation.duplicate_results",
+		metric.WithDescription("The number of results received for calculations that were no longer pending."),
+		metric.WithUnit("{result}"))
+	if err != nil {
+		return nil, err
+	}
+
 	h := &handler{
 		client:        c,
-		readQueueUrl:  readQueueUrl,
 		writeQueueUrl: writeQueueUrl,
 		store:         store,
+		notifiers:     notifiers,
+		latency:       latency,
+		duplicates:    duplicates,
 	}
 
-	go func() {
-		if err := h.receiveMessages(ctx); err != nil {
-			log.Printf("unable to receive queue messages: %v", err)
-		}
-	}()
+	h.consumer = queue.NewConsumer(queue.Config{
+		Client:             c,
+		QueueURL:           readQueueUrl,
+		DeadLetterQueueURL: deadLetterQueueUrl,
+		MaxReceiveCount:    cfg.SQSMaxReceiveCount,
+		TraceMode:          queue.TraceMode(cfg.SQSTraceMode),
+	}, h.applyResult)
 
 	return h, nil
 }
 
-func (h *handler) receiveMessages(ctx context.Context) error {
-	gMInput := &sqs.ReceiveMessageInput{
-		MessageAttributeNames: []string{"b3"},
-		QueueUrl:              aws.String(h.readQueueUrl),
-		VisibilityTimeout:     60,
-		WaitTimeSeconds:       10,
-	}
+// Run applies calculation results from the queue until ctx is done, then
+// waits for in-flight results to be stored.
+func (h *handler) Run(ctx context.Context) error {
+	return h.consumer.Run(ctx)
+}
 
-	for {
-		resp, err := h.client.ReceiveMessage(ctx, gMInput)
-		if err != nil {
-			return err
-		}
+const (
+	StatusPending   = "pending"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+type result struct {
+	Id      uuid.UUID `json:"id"`
+	Status  string    `json:"status"`
+	Result  float64   `json:"result"`
+	Decimal string    `json:"decimal"`
+	Error   string    `json:"error"`
+}
 
-		for _, msg := range resp.Messages {
-			ctx := otelsqs.NewCarrier().Extract(msg.MessageAttributes)
-			opts := []trace.SpanStartOption{
-				trace.WithSpanKind(trace.SpanKindConsumer),
-				trace.WithAttributes(
-					semconv.MessagingSystemKey.String("elasticmq"),
-					semconv.MessagingDestinationName(path.Base(h.readQueueUrl)),
-					semconv.MessagingMessageID(*msg.MessageId),
-				),
-			}
-			ctx, span := otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s process", path.Base(h.readQueueUrl)), opts...)
-
-			var rslt struct {
-				Id     uuid.UUID `json:"id"`
-				Result float64   `json:"result"`
-			}
-			if err := json.Unmarshal([]byte(*msg.Body), &rslt); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			if _, err := h.store.UpdateCalculation(ctx, postgres.UpdateCalculationParams{
-				ID: rslt.Id,
-				Result: pgtype.Float8{
-					Float64: rslt.Result,
-					Valid:   true,
-				},
-				Completed: pgtype.Timestamptz{
-					Time:  time.Now(),
-					Valid: true,
-				},
-			}); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			if _, err := h.client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
-				QueueUrl:      aws.String(h.readQueueUrl),
-				ReceiptHandle: msg.ReceiptHandle,
-			}); err != nil {
-				span.RecordError(err)
-				span.SetStatus(codes.Error, err.Error())
-				span.End()
-				return err
-			}
-
-			span.End()
+func (h *handler) applyResult(ctx context.Context, rslt result) error {
+	params := postgres.UpdateCalculationParams{
+		ID:     rslt.Id,
+		Status: StatusCompleted,
+		Completed: pgtype.Timestamptz{
+			Time:  time.Now(),
+			Valid: true,
+		},
+	}
+	if rslt.Status == StatusFailed {
+		params.Status = StatusFailed
+		params.Error = pgtype.Text{
+			String: rslt.Error,
+			Valid:  true,
+		}
+	} else {
+		params.Result = pgtype.Float8{
+			Float64: rslt.Result,
+			Valid:   true,
+		}
+		params.ResultDecimal = pgtype.Text{
+			String: rslt.Decimal,
+			Valid:  rslt.Decimal != "",
 		}
 	}
-}
 
-func (h *handler) Calculate(ctx context.Context, calc Calculation) error {
-	b, err := json.Marshal(calc)
+	calc, err := h.store.UpdateCalculation(ctx, params)
+	if errors.Is(err, postgres.ErrNotFound) {
+		// Retrying won't make the calculation appear.
+		return queue.Permanent(err)
+	}
+	if errors.Is(err, postgres.ErrAlreadyCompleted) {
+		// A redelivery of a result that was already stored; ack it.
+		trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("calculation.duplicate", true))
+		h.duplicates.Add(ctx, 1)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	sMInput := &sqs.SendMessageInput{
-		MessageAttributes: map[string]types.MessageAttributeValue{},
-		MessageBody:       aws.String(string(b)),
-		QueueUrl:          &h.writeQueueUrl,
+	h.latency.Record(ctx, params.Completed.Time.Sub(calc.Created).Seconds(),
+		metric.WithAttributes(attribute.String("status", params.Status)))
+
+	for _, n := range h.notifiers {
+		n.Publish(ctx, calc)
+	}
+
+	return nil
+}
+
+// Message is a calculation to enqueue and the context whose trace it
+// continues.
+type Message struct {
+	Ctx         context.Context
+	Calculation Calculation
+}
+
+// maxBatchSize is the most entries SendMessageBatch accepts.
+const maxBatchSize = 10
+
+// CalculateBatch enqueues msgs with SendMessageBatch, ten per call. It
+// returns one error per message, nil if the message was sent.
+func (h *handler) CalculateBatch(ctx context.Context, msgs []Message) []error {
+	errs := make([]error, len(msgs))
+	for start := 0; start < len(msgs); start += maxBatchSize {
+		end := min(start+maxBatchSize, len(msgs))
+		h.sendBatch(ctx, msgs[start:end], errs[start:end])
+	}
+	return errs
+}
+
+// sendBatch follows the messaging conventions for batches: each message gets
+// a create span in the trace of the request that created it, which the
+// consumer continues, and the SendMessageBatch call gets a send span linked
+// to all of them.
+func (h *handler) sendBatch(ctx context.Context, msgs []Message, errs []error) {
+	queueName := path.Base(h.writeQueueUrl)
+
+	entries := make([]types.SendMessageBatchRequestEntry, 0, len(msgs))
+	spans := make([]trace.Span, len(msgs))
+	links := make([]trace.Link, 0, len(msgs))
+	for i, msg := range msgs {
+		b, err := json.Marshal(msg.Calculation)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		opts := []trace.SpanStartOption{
+			trace.WithSpanKind(trace.SpanKindProducer),
+			trace.WithAttributes(
+				semconv.MessagingSystemKey.String(queue.MessagingSystem),
+				semconv.MessagingDestinationName(queueName),
+				semconv.MessagingOperationTypeCreate,
+			),
+		}
+		msgCtx, span := otelcommon.Tracer().Start(msg.Ctx, fmt.Sprintf("%s create", queueName), opts...)
+		defer span.End()
+		spans[i] = span
+		links = append(links, trace.Link{SpanContext: span.SpanContext()})
+
+		attrs := map[string]types.MessageAttributeValue{}
+		queue.NewCarrier().Inject(msgCtx, attrs)
+
+		entries = append(entries, types.SendMessageBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			MessageAttributes: attrs,
+			MessageBody:       aws.String(string(b)),
+		})
+	}
+
+	if len(entries) == 0 {
+		return
 	}
 
 	opts := []trace.SpanStartOption{
-		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithLinks(links...),
 		trace.WithAttributes(
-			semconv.MessagingSystemKey.String("elasticmq"),
-			semconv.MessagingDestinationName(path.Base(h.writeQueueUrl)),
+			semconv.MessagingSystemKey.String(queue.MessagingSystem),
+			semconv.MessagingDestinationName(queueName),
+			semconv.MessagingOperationTypePublish,
+			semconv.MessagingBatchMessageCount(len(entries)),
 		),
 	}
-	ctx, span := otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s send", path.Base(h.writeQueueUrl)), opts...)
-	otelsqs.NewCarrier().Inject(ctx, sMInput.MessageAttributes)
+	ctx, span := otelcommon.Tracer().Start(ctx, fmt.Sprintf("%s send", queueName), opts...)
 	defer span.End()
 
-	resp, err := h.client.SendMessage(ctx, sMInput)
+	resp, err := h.client.SendMessageBatch(ctx, &sqs.SendMessageBatchInput{
+		Entries:  entries,
+		QueueUrl: &h.writeQueueUrl,
+	})
 	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return err
+		for _, entry := range entries {
+			i, _ := strconv.Atoi(*entry.Id)
+			errs[i] = err
+			spans[i].SetStatus(codes.Error, err.Error())
+		}
+		return
 	}
 
-	span.SetAttributes(semconv.MessagingMessageID(*resp.MessageId))
+	for _, ok := range resp.Successful {
+		i, _ := strconv.Atoi(*ok.Id)
+		spans[i].SetAttributes(semconv.MessagingMessageID(*ok.MessageId))
+	}
 
-	return nil
+	for _, failed := range resp.Failed {
+		i, _ := strconv.Atoi(*failed.Id)
+		errs[i] = fmt.Errorf("%s: %s", aws.ToString(failed.Code), aws.ToString(failed.Message))
+		spans[i].RecordError(errs[i])
+		spans[i].SetStatus(codes.Error, errs[i].Error())
+	}
+	if len(resp.Failed) > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d messages failed", len(resp.Failed), len(entries)))
+	}
 }
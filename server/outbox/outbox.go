@@ -0,0 +1,270 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/MukeshGKastala/nola-otel-demo/server/math"
+	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+type Store interface {
+	ExecTx(context.Context, func(*postgres.Store) error) error
+	DeleteSentOutboxMessages(context.Context, postgres.DeleteSentOutboxMessagesParams) (int64, error)
+}
+
+type Publisher interface {
+	CalculateBatch(context.Context, []math.Message) []error
+}
+
+// NewMessage encodes calc, together with the trace context of ctx, as an
+// outbox row. Insert it in the same transaction as the calculation.
+func NewMessage(ctx context.Context, calc math.Calculation) (postgres.CreateOutboxMessageParams, error) {
+	payload, err := json.Marshal(calc)
+	if err != nil {
+		return postgres.CreateOutboxMessageParams{}, err
+	}
+
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	traceContext, err := json.Marshal(carrier)
+	if err != nil {
+		return postgres.CreateOutboxMessageParams{}, err
+	}
+
+	return postgres.CreateOutboxMessageParams{
+		Payload:      payload,
+		TraceContext: traceContext,
+	}, nil
+}
+
+type Config struct {
+	// PollInterval is how often pending rows are looked for when no
+	// Notify arrives. Defaults to 1s.
+	PollInterval time.Duration
+	// BatchSize is the maximum number of rows published per poll.
+	// Defaults to 10.
+	BatchSize int
+	// MaxAttempts is how many times a row is published before it is
+	// parked and no longer relayed. Defaults to 10.
+	MaxAttempts int
+	// InitialBackoff is how long a row is held back after its first failed
+	// attempt, doubling after each further one. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps how long a row is held back. Defaults to 5m.
+	MaxBackoff time.Duration
+	// Retention is how long sent rows are kept before they are deleted.
+	// Defaults to 24h.
+	Retention time.Duration
+}
+
+const (
+	purgeInterval  = time.Minute
+	purgeBatchSize = 1000
+)
+
+// Relay publishes pending outbox rows to the math queue and marks them sent.
+// A row is only marked sent once it has been published, so delivery is
+// at-least-once. A row that fails is held back with exponential backoff, so
+// it doesn't hold up the rows behind it, and parked once it has failed
+// MaxAttempts times. Parked rows are kept, with their last error, for
+// inspection.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	cfg       Config
+	notify    chan struct{}
+}
+
+func NewRelay(cfg Config, store Store, publisher Publisher) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 10
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = 5 * time.Minute
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = 24 * time.Hour
+	}
+
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		cfg:       cfg,
+		notify:    make(chan struct{}, 1),
+	}
+}
+
+// Notify wakes the relay without waiting for the next poll. It never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Run publishes pending rows until ctx is done. A batch that is already being
+// published is allowed to finish. While batches come back full the next one
+// is relayed straight away, so a backlog drains at the speed of the queue.
+// Sent rows older than Retention are deleted every minute.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+	purge := time.NewTicker(purgeInterval)
+	defer purge.Stop()
+
+	for {
+		more, err := r.relay(context.WithoutCancel(ctx))
+		if err != nil {
+			slog.ErrorContext(ctx, "Unable to relay outbox messages", "error", err)
+		}
+
+		if more && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-r.notify:
+		case <-purge.C:
+			if err := r.purge(context.WithoutCancel(ctx)); err != nil {
+				slog.ErrorContext(ctx, "Unable to purge sent outbox messages", "error", err)
+			}
+		}
+	}
+}
+
+// relay publishes one batch. It reports whether more rows may be pending:
+// the batch was full and at least one row was sent, so retrying straight
+// away can't spin on rows that keep failing.
+func (r *Relay) relay(ctx context.Context) (bool, error) {
+	var full bool
+	var sent int
+	err := r.store.ExecTx(ctx, func(tx *postgres.Store) error {
+		rows, err := tx.ListPendingOutboxMessages(ctx, int32(r.cfg.BatchSize))
+		if err != nil {
+			return err
+		}
+		full = len(rows) == r.cfg.BatchSize
+
+		decoded := make([]postgres.Outbox, 0, len(rows))
+		msgs := make([]math.Message, 0, len(rows))
+		for _, row := range rows {
+			msg, err := decode(row)
+			if err != nil {
+				// Retrying can't fix a row that doesn't decode.
+				slog.ErrorContext(ctx, "Parking undecodable outbox message", "id", row.ID, "error", err)
+				if err := tx.ParkOutboxMessage(ctx, postgres.ParkOutboxMessageParams{
+					ID:        row.ID,
+					LastError: pgtype.Text{String: err.Error(), Valid: true},
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			decoded = append(decoded, row)
+			msgs = append(msgs, msg)
+		}
+
+		for i, err := range r.publisher.CalculateBatch(ctx, msgs) {
+			if err != nil {
+				if err := r.fail(msgs[i].Ctx, tx, decoded[i], err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := tx.MarkOutboxMessageSent(ctx, decoded[i].ID); err != nil {
+				return err
+			}
+			sent++
+		}
+
+		return nil
+	})
+	return full && sent > 0 && err == nil, err
+}
+
+// fail records a failed attempt to publish row, holding it back or, after
+// MaxAttempts, parking it.
+func (r *Relay) fail(ctx context.Context, tx *postgres.Store, row postgres.Outbox, err error) error {
+	attempts := int(row.Attempts) + 1
+	lastError := pgtype.Text{String: err.Error(), Valid: true}
+
+	if attempts >= r.cfg.MaxAttempts {
+		slog.ErrorContext(ctx, "Giving up on outbox message", "id", row.ID, "attempts", attempts, "error", err)
+		return tx.ParkOutboxMessage(ctx, postgres.ParkOutboxMessageParams{
+			ID:        row.ID,
+			LastError: lastError,
+		})
+	}
+
+	slog.WarnContext(ctx, "Unable to publish outbox message", "id", row.ID, "attempts", attempts, "error", err)
+	return tx.RetryOutboxMessage(ctx, postgres.RetryOutboxMessageParams{
+		ID:          row.ID,
+		LastError:   lastError,
+		NextAttempt: time.Now().Add(r.backoff(attempts)),
+	})
+}
+
+// backoff returns how long to hold a row back after its attempts-th failure.
+func (r *Relay) backoff(attempts int) time.Duration {
+	backoff := r.cfg.InitialBackoff
+	for i := 1; i < attempts && backoff < r.cfg.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, r.cfg.MaxBackoff)
+}
+
+// purge deletes sent rows older than Retention, a batch at a time so that no
+// single statement holds locks for long.
+func (r *Relay) purge(ctx context.Context) error {
+	sentBefore := pgtype.Timestamptz{Time: time.Now().Add(-r.cfg.Retention), Valid: true}
+	for {
+		n, err := r.store.DeleteSentOutboxMessages(ctx, postgres.DeleteSentOutboxMessagesParams{
+			Limit:      purgeBatchSize,
+			SentBefore: sentBefore,
+		})
+		if err != nil {
+			return err
+		}
+		if n < purgeBatchSize {
+			return nil
+		}
+	}
+}
+
+func decode(row postgres.Outbox) (math.Message, error) {
+	var calc math.Calculation
+	if err := json.Unmarshal(row.Payload, &calc); err != nil {
+		return math.Message{}, err
+	}
+
+	var carrier propagation.MapCarrier
+	if err := json.Unmarshal(row.TraceContext, &carrier); err != nil {
+		return math.Message{}, err
+	}
+
+	// Continue the trace of the request that created the calculation.
+	return math.Message{
+		Ctx:         otel.GetTextMapPropagator().Extract(context.Background(), carrier),
+		Calculation: calc,
+	}, nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	api "github.com/MukeshGKastala/nola-otel-demo/server/api/calculator/v1"
+	"github.com/MukeshGKastala/nola-otel-demo/server/events"
+	"github.com/MukeshGKastala/nola-otel-demo/server/math"
+	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
+)
+
+func (s *service) StreamCalculationEvents(ctx context.Context, request api.StreamCalculationEventsRequestObject) (api.StreamCalculationEventsResponseObject, error) {
+	student := request.Params.Student
+
+	results, cancel := s.broker.Subscribe(func(calc postgres.Calculation) bool {
+		return student == nil || calc.Student == *student
+	})
+
+	return eventStream{ctx: ctx, events: results, cancel: cancel}, nil
+}
+
+func (s *service) StreamCalculationEvent(ctx context.Context, request api.StreamCalculationEventRequestObject) (api.StreamCalculationEventResponseObject, error) {
+	results, cancel := s.broker.Subscribe(func(calc postgres.Calculation) bool {
+		return calc.ID == request.Uuid
+	})
+
+	// Subscribe first so a result applied in between isn't missed.
+	calc, err := s.store.GetCalculation(ctx, request.Uuid)
+	if errors.Is(err, postgres.ErrNotFound) {
+		cancel()
+		return api.StreamCalculationEvent404JSONResponse{
+			NotFoundJSONResponse: api.NotFoundJSONResponse{
+				Code:    api.CodeNotFound,
+				Message: "calculation not found",
+			},
+		}, nil
+	}
+	if err != nil {
+		cancel()
+		return api.StreamCalculationEventdefaultJSONResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body: api.Error{
+				Code:    api.CodeDatabaseReadFailure,
+				Message: "database read failure",
+			},
+		}, nil
+	}
+
+	stream := eventStream{ctx: ctx, events: results, cancel: cancel, once: true}
+	if calc.Status != math.StatusPending {
+		stream.first = &events.Event{Calculation: calc}
+	}
+
+	return stream, nil
+}
+
+const heartbeatInterval = 15 * time.Second
+
+// eventStream writes calculation events as server-sent events, flushing each
+// one, until the client goes away or the broker closes.
+type eventStream struct {
+	ctx    context.Context
+	events <-chan events.Event
+	cancel func()
+	// first is written before any event from the broker.
+	first *events.Event
+	// once ends the stream after the first event.
+	once bool
+}
+
+func (e eventStream) VisitStreamCalculationEventsResponse(w http.ResponseWriter) error {
+	return e.stream(w)
+}
+
+func (e eventStream) VisitStreamCalculationEventResponse(w http.ResponseWriter) error {
+	return e.stream(w)
+}
+
+func (e eventStream) stream(w http.ResponseWriter) error {
+	defer e.cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	// Flushing sends the headers, and fails before anything is written if
+	// the connection can't stream.
+	rc := http.NewResponseController(w)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+
+	// Write errors mean the client has gone, which ends the stream as
+	// expected, so they aren't returned.
+	if e.first != nil {
+		if writeEvent(w, *e.first) != nil || rc.Flush() != nil || e.once {
+			return nil
+		}
+	}
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return nil
+		case event, ok := <-e.events:
+			if !ok {
+				return nil
+			}
+			if writeEvent(w, event) != nil || rc.Flush() != nil || e.once {
+				return nil
+			}
+		case <-heartbeat.C:
+			// A comment keeps idle connections open through proxies.
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil || rc.Flush() != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, event events.Event) error {
+	data := api.CalculationEvent{
+		Calculation: NewCalculationResponse(event.Calculation),
+	}
+	if event.TraceID != "" {
+		data.TraceId = &event.TraceID
+	}
+
+	b, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: calculation\ndata: %s\n\n", event.Calculation.ID, b)
+	return err
+}
@@ -2,37 +2,74 @@ package service
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/MukeshGKastala/nola-otel-demo/common/expr"
 	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
 	api "github.com/MukeshGKastala/nola-otel-demo/server/api/calculator/v1"
+	"github.com/MukeshGKastala/nola-otel-demo/server/egress"
+	"github.com/MukeshGKastala/nola-otel-demo/server/events"
 	"github.com/MukeshGKastala/nola-otel-demo/server/math"
+	"github.com/MukeshGKastala/nola-otel-demo/server/outbox"
 	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Store interface {
-	CreateCalculation(context.Context, postgres.CreateCalculationParams) (uuid.UUID, error)
 	GetCalculation(context.Context, uuid.UUID) (postgres.Calculation, error)
+	ListCalculations(context.Context, postgres.ListCalculationsParams) ([]postgres.Calculation, error)
+	GetIdempotencyKey(context.Context, postgres.GetIdempotencyKeyParams) (postgres.IdempotencyKey, error)
+	UpdateIdempotencyKeyStatusCode(context.Context, postgres.UpdateIdempotencyKeyStatusCodeParams) error
+	ExecTx(context.Context, func(*postgres.Store) error) error
 }
 
-type Math interface {
-	Calculate(context.Context, math.Calculation) error
+type Outbox interface {
+	Notify()
+}
+
+type Broker interface {
+	Subscribe(match func(postgres.Calculation) bool) (<-chan events.Event, func())
 }
 
 type service struct {
-	store Store
-	math  Math
+	store  Store
+	outbox Outbox
+	broker Broker
 }
 
-func NewService(store Store, math Math) *service {
-	return &service{store: store, math: math}
+func NewService(store Store, outbox Outbox, broker Broker) *service {
+	return &service{store: store, outbox: outbox, broker: broker}
 }
 
 func (s *service) CreateCalculation(ctx context.Context, request api.CreateCalculationRequestObject) (api.CreateCalculationResponseObject, error) {
+	if id := request.Params.XRequestID; id != nil && len(*id) > maxRequestIDLength {
+		return api.CreateCalculation400JSONResponse{
+			BadRequestJSONResponse: api.BadRequestJSONResponse{
+				Code:    api.CodeInvalidRequest,
+				Message: fmt.Sprintf("X-Request-ID must be at most %d characters", maxRequestIDLength),
+			},
+		}, nil
+	}
+
+	// Baggage carries the student, and the request ID, to every span of the
+	// calculation, including those across the queue.
+	ctx = otelcommon.WithBaggage(ctx, otelcommon.BaggageStudent, request.Body.Student)
+	if id := request.Params.XRequestID; id != nil {
+		ctx = otelcommon.WithBaggage(ctx, otelcommon.BaggageRequestID, *id)
+	}
+
 	opts := []trace.SpanStartOption{
 		trace.WithAttributes(
 			attribute.String("expression", request.Body.Expression),
@@ -45,54 +82,649 @@ func (s *service) CreateCalculation(ctx context.Context, request api.CreateCalcu
 	// Imitate work
 	time.Sleep(30 * time.Millisecond)
 
-	id, err := s.store.CreateCalculation(ctx, postgres.CreateCalculationParams{
-		Student:    request.Body.Student,
-		Expression: request.Body.Expression,
+	calculation, callbackURL, msg := validateCalculation(*request.Body)
+	if msg != "" {
+		return api.CreateCalculation400JSONResponse{
+			BadRequestJSONResponse: api.BadRequestJSONResponse{
+				Code:    api.CodeInvalidRequest,
+				Message: msg,
+			},
+		}, nil
+	}
+
+	wait, err := parseWait(request.Params)
+	if err != nil {
+		return api.CreateCalculation400JSONResponse{
+			BadRequestJSONResponse: api.BadRequestJSONResponse{
+				Code:    api.CodeInvalidRequest,
+				Message: err.Error(),
+			},
+		}, nil
+	}
+
+	variablesJSON, err := marshalVariables(calculation.Variables)
+	if err != nil {
+		return nil, err
+	}
+
+	key := request.Params.IdempotencyKey
+	retainedSince := time.Now().Add(-idempotencyKeyRetention)
+	if key != nil {
+		if len(*key) == 0 || len(*key) > maxIdempotencyKeyLength {
+			return api.CreateCalculation400JSONResponse{
+				BadRequestJSONResponse: api.BadRequestJSONResponse{
+					Code:    api.CodeInvalidRequest,
+					Message: fmt.Sprintf("Idempotency-Key must be between 1 and %d characters", maxIdempotencyKeyLength),
+				},
+			}, nil
+		}
+
+		span.SetAttributes(attribute.String("idempotency_key", *key))
+
+		resp, err := s.replayCalculation(ctx, *key, retainedSince)
+		if !errors.Is(err, postgres.ErrNotFound) {
+			return resp, err
+		}
+	}
+
+	// The calculation is enqueued by the outbox relay once the transaction
+	// commits, so a row never exists without its queue message.
+	var calc postgres.Calculation
+	err = s.store.ExecTx(ctx, func(tx *postgres.Store) error {
+		var err error
+		calc, err = tx.CreateCalculation(ctx, postgres.CreateCalculationParams{
+			Student:     calculation.Student,
+			Expression:  calculation.Expression,
+			Variables:   variablesJSON,
+			Mode:        calculation.Mode,
+			CallbackUrl: callbackURL,
+		})
+		if err != nil {
+			return err
+		}
+
+		calculation.ID = calc.ID
+		msg, err := outbox.NewMessage(ctx, calculation)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.CreateOutboxMessage(ctx, msg); err != nil {
+			return err
+		}
+
+		if key == nil {
+			return nil
+		}
+
+		_, err = tx.CreateIdempotencyKey(ctx, postgres.CreateIdempotencyKeyParams{
+			Key:           *key,
+			CalculationID: calc.ID,
+			StatusCode:    http.StatusOK,
+			RetainedSince: retainedSince,
+		})
+		return err
 	})
+	if errors.Is(err, postgres.ErrIdempotencyKeyInUse) {
+		// A concurrent request with the same key committed first.
+		resp, err := s.replayCalculation(ctx, *key, retainedSince)
+		if !errors.Is(err, postgres.ErrNotFound) {
+			return resp, err
+		}
+	}
 	if err != nil {
 		return api.CreateCalculationdefaultJSONResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body: api.Error{
+				Code:    api.CodeDatabaseWriteFailure,
 				Message: "database write failure",
 			},
 		}, nil
 	}
 
-	if err := s.math.Calculate(ctx, math.Calculation{
-		ID:         id,
-		Student:    request.Body.Student,
-		Expression: request.Body.Expression,
-	}); err != nil {
+	s.outbox.Notify()
+
+	if wait == 0 {
+		return api.CreateCalculation200JSONResponse(NewCalculationResponse(calc)), nil
+	}
+
+	span.SetAttributes(attribute.Float64("calculation.wait", wait.Seconds()))
+
+	done, err := s.awaitCalculation(ctx, calc.ID, wait)
+	span.SetAttributes(attribute.Bool("calculation.wait.completed", err == nil))
+	if err == nil {
+		return api.CreateCalculation200JSONResponse(NewCalculationResponse(done)), nil
+	}
+
+	if key != nil {
+		// Replays must answer as this request does.
+		if err := s.store.UpdateIdempotencyKeyStatusCode(context.WithoutCancel(ctx), postgres.UpdateIdempotencyKeyStatusCodeParams{
+			StatusCode:    http.StatusAccepted,
+			Key:           *key,
+			CalculationID: calc.ID,
+		}); err != nil {
+			slog.ErrorContext(ctx, "Unable to update idempotency key", "error", err)
+		}
+	}
+
+	return api.CreateCalculation202JSONResponse{
+		Id: calc.ID,
+	}, nil
+}
+
+const maxWait = 30 * time.Second
+
+// parseWait returns how long the request asks to wait for its result. The
+// wait query parameter takes precedence over a Prefer header's wait
+// preference.
+func parseWait(params api.CreateCalculationParams) (time.Duration, error) {
+	if params.Wait != nil {
+		d, err := time.ParseDuration(*params.Wait)
+		if err != nil || d < 0 || d > maxWait {
+			return 0, fmt.Errorf("wait must be a duration between 0s and %s", maxWait)
+		}
+		return d, nil
+	}
+
+	if params.Prefer != nil {
+		for _, pref := range strings.Split(*params.Prefer, ",") {
+			pref, _, _ = strings.Cut(pref, ";")
+			name, value, _ := strings.Cut(pref, "=")
+			if !strings.EqualFold(strings.TrimSpace(name), "wait") {
+				continue
+			}
+			// Preferences are hints, so an unusable one is ignored
+			// and a long one is shortened.
+			seconds, err := strconv.Atoi(strings.Trim(strings.TrimSpace(value), `"`))
+			if err != nil || seconds < 0 {
+				return 0, nil
+			}
+			return min(time.Duration(seconds)*time.Second, maxWait), nil
+		}
+	}
+
+	return 0, nil
+}
+
+// errWaitElapsed is returned by awaitCalculation when the calculation is
+// still pending after the wait.
+var errWaitElapsed = errors.New("wait elapsed")
+
+// awaitCalculation waits up to wait for the calculation to finish.
+func (s *service) awaitCalculation(ctx context.Context, id uuid.UUID, wait time.Duration) (postgres.Calculation, error) {
+	results, cancel := s.broker.Subscribe(func(calc postgres.Calculation) bool {
+		return calc.ID == id
+	})
+	defer cancel()
+
+	// The result may have been applied before subscribing.
+	calc, err := s.store.GetCalculation(ctx, id)
+	if err != nil {
+		return calc, err
+	}
+	if calc.Status != math.StatusPending {
+		return calc, nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case event, ok := <-results:
+		if !ok {
+			return calc, errWaitElapsed
+		}
+		return event.Calculation, nil
+	case <-timer.C:
+		return calc, errWaitElapsed
+	case <-ctx.Done():
+		return calc, ctx.Err()
+	}
+}
+
+const maxRequestIDLength = 255
+
+const maxBatchSize = 500
+
+func (s *service) CreateCalculationBatch(ctx context.Context, request api.CreateCalculationBatchRequestObject) (api.CreateCalculationBatchResponseObject, error) {
+	items := request.Body.Calculations
+	if len(items) == 0 || len(items) > maxBatchSize {
+		return api.CreateCalculationBatch400JSONResponse{
+			BadRequestJSONResponse: api.BadRequestJSONResponse{
+				Code:    api.CodeInvalidRequest,
+				Message: fmt.Sprintf("calculations must have between 1 and %d items", maxBatchSize),
+			},
+		}, nil
+	}
+
+	if id := request.Params.XRequestID; id != nil && len(*id) > maxRequestIDLength {
+		return api.CreateCalculationBatch400JSONResponse{
+			BadRequestJSONResponse: api.BadRequestJSONResponse{
+				Code:    api.CodeInvalidRequest,
+				Message: fmt.Sprintf("X-Request-ID must be at most %d characters", maxRequestIDLength),
+			},
+		}, nil
+	}
+
+	// Each calculation adds its own student to the baggage.
+	if id := request.Params.XRequestID; id != nil {
+		ctx = otelcommon.WithBaggage(ctx, otelcommon.BaggageRequestID, *id)
+	}
+
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(attribute.Int("calculation.batch.size", len(items))),
+	}
+	ctx, span := otelcommon.Tracer().Start(ctx, "create calculation batch service", opts...)
+	defer span.End()
+
+	results := make([]api.CreateCalculationBatchResult, len(items))
+	calcs := make([]postgres.CreateCalculationsParams, 0, len(items))
+	msgs := make([]postgres.CreateOutboxMessagesParams, 0, len(items))
+	for i, item := range items {
+		calculation, callbackURL, msg := validateCalculation(item)
+		if msg != "" {
+			results[i].Error = &api.Error{
+				Code:    api.CodeInvalidRequest,
+				Message: msg,
+			}
+			continue
+		}
+
+		variablesJSON, err := marshalVariables(calculation.Variables)
+		if err != nil {
+			return nil, err
+		}
+
+		// IDs are assigned here, rather than by the database, so each
+		// outbox message can be built before the rows are copied in.
+		calculation.ID = uuid.New()
+		results[i].Id = &calculation.ID
+
+		outboxMsg, err := newBatchMessage(ctx, calculation)
+		if err != nil {
+			return nil, err
+		}
+
+		calcs = append(calcs, postgres.CreateCalculationsParams{
+			ID:          calculation.ID,
+			Student:     calculation.Student,
+			Expression:  calculation.Expression,
+			Variables:   variablesJSON,
+			Mode:        calculation.Mode,
+			CallbackUrl: callbackURL,
+		})
+		msgs = append(msgs, postgres.CreateOutboxMessagesParams(outboxMsg))
+	}
+
+	span.SetAttributes(attribute.Int("calculation.batch.rejected", len(items)-len(calcs)))
+
+	if len(calcs) > 0 {
+		err := s.store.ExecTx(ctx, func(tx *postgres.Store) error {
+			if _, err := tx.CreateCalculations(ctx, calcs); err != nil {
+				return err
+			}
+			_, err := tx.CreateOutboxMessages(ctx, msgs)
+			return err
+		})
+		if err != nil {
+			return api.CreateCalculationBatchdefaultJSONResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body: api.Error{
+					Code:    api.CodeDatabaseWriteFailure,
+					Message: "database write failure",
+				},
+			}, nil
+		}
+
+		s.outbox.Notify()
+	}
+
+	return api.CreateCalculationBatch200JSONResponse{
+		Results: results,
+	}, nil
+}
+
+// newBatchMessage builds the outbox message for one calculation of a batch
+// under its own child span, so each calculation's trace can be told apart
+// from its siblings'.
+func newBatchMessage(ctx context.Context, calculation math.Calculation) (postgres.CreateOutboxMessageParams, error) {
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(
+			attribute.String("calculation.id", calculation.ID.String()),
+			attribute.String("expression", calculation.Expression),
+			attribute.String("student", calculation.Student),
+		),
+	}
+	ctx = otelcommon.WithBaggage(ctx, otelcommon.BaggageStudent, calculation.Student)
+	ctx, span := otelcommon.Tracer().Start(ctx, "create calculation", opts...)
+	defer span.End()
+
+	return outbox.NewMessage(ctx, calculation)
+}
+
+// validateCalculation returns the calculation body describes, without an ID,
+// and its callback URL, or why body is invalid.
+func validateCalculation(body api.CreateCalculationRequest) (math.Calculation, pgtype.Text, string) {
+	if len(body.Student) > maxStudentLength {
+		return math.Calculation{}, pgtype.Text{}, fmt.Sprintf("student must be at most %d characters", maxStudentLength)
+	}
+
+	mode := api.Float
+	if body.Mode != nil {
+		mode = *body.Mode
+	}
+	if mode != api.Float && mode != api.Decimal {
+		return math.Calculation{}, pgtype.Text{}, fmt.Sprintf("mode must be %q or %q", api.Float, api.Decimal)
+	}
+
+	var variables map[string]float64
+	if body.Variables != nil {
+		variables = *body.Variables
+	}
+	if msg := validateExpression(body.Expression, variables); msg != "" {
+		return math.Calculation{}, pgtype.Text{}, msg
+	}
+
+	var callbackURL pgtype.Text
+	if body.CallbackUrl != nil {
+		if msg := validateCallbackURL(*body.CallbackUrl); msg != "" {
+			return math.Calculation{}, pgtype.Text{}, msg
+		}
+		callbackURL = pgtype.Text{String: *body.CallbackUrl, Valid: true}
+	}
+
+	return math.Calculation{
+		Student:    body.Student,
+		Expression: body.Expression,
+		Variables:  variables,
+		Mode:       string(mode),
+	}, callbackURL, ""
+}
+
+// marshalVariables encodes variables for storage, leaving them NULL if there
+// are none.
+func marshalVariables(variables map[string]float64) ([]byte, error) {
+	if variables == nil {
+		return nil, nil
+	}
+	return json.Marshal(variables)
+}
+
+// These bounds keep a calculation's queue message to about 10 KiB, well
+// inside the 256 KiB SQS allows for a whole SendMessageBatch of ten.
+const (
+	maxStudentLength      = 255
+	maxExpressionLength   = 1024
+	maxVariables          = 100
+	maxVariableNameLength = 64
+)
+
+// validateExpression returns why expression can't be evaluated with
+// variables, or "" if it can.
+func validateExpression(expression string, variables map[string]float64) string {
+	if len(expression) > maxExpressionLength {
+		return fmt.Sprintf("expression must be at most %d characters", maxExpressionLength)
+	}
+	if len(variables) > maxVariables {
+		return fmt.Sprintf("at most %d variables are allowed", maxVariables)
+	}
+	for name := range variables {
+		if len(name) > maxVariableNameLength {
+			return fmt.Sprintf("variable names must be at most %d characters", maxVariableNameLength)
+		}
+		if !expr.IsIdentifier(name) {
+			return fmt.Sprintf("invalid variable name %q", name)
+		}
+	}
+	if unknown := expr.UnknownIdentifiers(expression, variables); len(unknown) > 0 {
+		return fmt.Sprintf("unknown identifiers: %s", strings.Join(unknown, ", "))
+	}
+	return ""
+}
+
+const maxCallbackURLLength = 2048
+
+// validateCallbackURL returns why rawURL can't be used as a callback, or ""
+// if it can.
+func validateCallbackURL(rawURL string) string {
+	if len(rawURL) > maxCallbackURLLength {
+		return fmt.Sprintf("callbackUrl must be at most %d characters", maxCallbackURLLength)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "callbackUrl must be an absolute http or https URL"
+	}
+
+	// Hostnames that resolve to an internal address are refused when the
+	// webhook connects.
+	switch err := egress.CheckHost(u.Hostname()); {
+	case errors.Is(err, egress.ErrInternalAddress):
+		return "callbackUrl must not point to a loopback, private or link-local address"
+	case err != nil:
+		return "callbackUrl must have a public hostname"
+	}
+	return ""
+}
+
+const (
+	idempotencyKeyRetention = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+)
+
+// replayCalculation responds as the request that claimed key did. It returns
+// postgres.ErrNotFound if key is unclaimed or past its retention window.
+func (s *service) replayCalculation(ctx context.Context, key string, retainedSince time.Time) (api.CreateCalculationResponseObject, error) {
+	ik, err := s.store.GetIdempotencyKey(ctx, postgres.GetIdempotencyKeyParams{
+		Key:           key,
+		RetainedSince: retainedSince,
+	})
+	if errors.Is(err, postgres.ErrNotFound) {
+		return nil, err
+	}
+	if err != nil {
 		return api.CreateCalculationdefaultJSONResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body: api.Error{
-				Message: "queue write failure",
+				Code:    api.CodeDatabaseReadFailure,
+				Message: "database read failure",
 			},
 		}, nil
 	}
 
-	return api.CreateCalculation200JSONResponse{
-		Id: id,
-	}, nil
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("idempotency_key.replayed", true))
+
+	switch ik.StatusCode {
+	case http.StatusOK:
+		calc, err := s.store.GetCalculation(ctx, ik.CalculationID)
+		if err != nil {
+			return api.CreateCalculationdefaultJSONResponse{
+				StatusCode: http.StatusInternalServerError,
+				Body: api.Error{
+					Code:    api.CodeDatabaseReadFailure,
+					Message: "database read failure",
+				},
+			}, nil
+		}
+		return api.CreateCalculation200JSONResponse(NewCalculationResponse(calc)), nil
+	case http.StatusAccepted:
+		return api.CreateCalculation202JSONResponse{
+			Id: ik.CalculationID,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unexpected status code %d for idempotency key", ik.StatusCode)
+	}
 }
 
 func (s *service) GetCalculation(ctx context.Context, request api.GetCalculationRequestObject) (api.GetCalculationResponseObject, error) {
 	calc, err := s.store.GetCalculation(ctx, request.Uuid)
+	if errors.Is(err, postgres.ErrNotFound) {
+		return api.GetCalculation404JSONResponse{
+			NotFoundJSONResponse: api.NotFoundJSONResponse{
+				Code:    api.CodeNotFound,
+				Message: "calculation not found",
+			},
+		}, nil
+	}
 	if err != nil {
 		return api.GetCalculationdefaultJSONResponse{
 			StatusCode: http.StatusInternalServerError,
 			Body: api.Error{
+				Code:    api.CodeDatabaseReadFailure,
+				Message: "database read failure",
+			},
+		}, nil
+	}
+
+	return api.GetCalculation200JSONResponse(NewCalculationResponse(calc)), nil
+}
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+func (s *service) ListCalculations(ctx context.Context, request api.ListCalculationsRequestObject) (api.ListCalculationsResponseObject, error) {
+	params := request.Params
+
+	limit := int32(defaultListLimit)
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+	if limit < 1 || limit > maxListLimit {
+		return api.ListCalculations400JSONResponse{
+			BadRequestJSONResponse: api.BadRequestJSONResponse{
+				Code:    api.CodeInvalidRequest,
+				Message: fmt.Sprintf("limit must be between 1 and %d", maxListLimit),
+			},
+		}, nil
+	}
+
+	arg := postgres.ListCalculationsParams{
+		// Fetch one extra row to learn whether there is a next page.
+		Limit: limit + 1,
+	}
+	if params.Student != nil {
+		arg.Student = pgtype.Text{String: *params.Student, Valid: true}
+	}
+	if params.Status != nil {
+		switch *params.Status {
+		case api.Pending, api.Completed, api.Failed:
+		default:
+			return api.ListCalculations400JSONResponse{
+				BadRequestJSONResponse: api.BadRequestJSONResponse{
+					Code:    api.CodeInvalidRequest,
+					Message: fmt.Sprintf("status must be one of %s, %s or %s", api.Pending, api.Completed, api.Failed),
+				},
+			}, nil
+		}
+		arg.Status = pgtype.Text{String: string(*params.Status), Valid: true}
+	}
+	if params.CreatedAfter != nil {
+		arg.CreatedAfter = pgtype.Timestamptz{Time: *params.CreatedAfter, Valid: true}
+	}
+	if params.CreatedBefore != nil {
+		arg.CreatedBefore = pgtype.Timestamptz{Time: *params.CreatedBefore, Valid: true}
+	}
+	if params.Cursor != nil {
+		created, id, err := decodeCursor(*params.Cursor)
+		if err != nil {
+			return api.ListCalculations400JSONResponse{
+				BadRequestJSONResponse: api.BadRequestJSONResponse{
+					Code:    api.CodeInvalidRequest,
+					Message: "invalid cursor",
+				},
+			}, nil
+		}
+		arg.CursorCreated = pgtype.Timestamptz{Time: created, Valid: true}
+		arg.CursorID = pgtype.UUID{Bytes: id, Valid: true}
+	}
+
+	calcs, err := s.store.ListCalculations(ctx, arg)
+	if err != nil {
+		return api.ListCalculationsdefaultJSONResponse{
+			StatusCode: http.StatusInternalServerError,
+			Body: api.Error{
+				Code:    api.CodeDatabaseReadFailure,
 				Message: "database read failure",
 			},
 		}, nil
 	}
 
-	return api.GetCalculation200JSONResponse{
+	var resp api.ListCalculations200JSONResponse
+	if len(calcs) > int(limit) {
+		calcs = calcs[:limit]
+		last := calcs[len(calcs)-1]
+		cursor := encodeCursor(last.Created, last.ID)
+		resp.NextCursor = &cursor
+	}
+
+	resp.Calculations = make([]api.CalculationResponse, len(calcs))
+	for i, calc := range calcs {
+		resp.Calculations[i] = NewCalculationResponse(calc)
+	}
+
+	return resp, nil
+}
+
+// NewCalculationResponse converts a stored calculation to its API form.
+func NewCalculationResponse(calc postgres.Calculation) api.CalculationResponse {
+	resp := api.CalculationResponse{
 		Id:         calc.ID,
 		Student:    calc.Student,
 		Expression: calc.Expression,
-		Result:     calc.Result.Float64,
+		Mode:       api.CalculationMode(calc.Mode),
+		Status:     api.CalculationStatus(calc.Status),
 		Created:    calc.Created,
-		Completed:  calc.Completed.Time,
-	}, nil
+	}
+	if calc.Variables != nil {
+		var variables api.Variables
+		if err := json.Unmarshal(calc.Variables, &variables); err == nil {
+			resp.Variables = &variables
+		}
+	}
+	if calc.Result.Valid {
+		resp.Result = &calc.Result.Float64
+	}
+	if calc.ResultDecimal.Valid {
+		resp.DecimalResult = &calc.ResultDecimal.String
+	}
+	if calc.Error.Valid {
+		resp.Error = &calc.Error.String
+	}
+	if calc.Completed.Valid {
+		resp.Completed = &calc.Completed.Time
+	}
+	if calc.CallbackUrl.Valid {
+		resp.CallbackUrl = &calc.CallbackUrl.String
+	}
+	return resp
+}
+
+// A cursor is the (created, id) key of the last calculation on a page.
+func encodeCursor(created time.Time, id uuid.UUID) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(created.Format(time.RFC3339Nano) + "," + id.String()))
+}
+
+func decodeCursor(cursor string) (time.Time, uuid.UUID, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+
+	created, id, ok := strings.Cut(string(b), ",")
+	if !ok {
+		return time.Time{}, uuid.Nil, errors.New("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, created)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+
+	u, err := uuid.Parse(id)
+	if err != nil {
+		return time.Time{}, uuid.Nil, err
+	}
+
+	return t, u, nil
 }
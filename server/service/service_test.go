@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/MukeshGKastala/nola-otel-demo/server/api/calculator/v1"
+	"github.com/google/uuid"
+)
+
+func TestValidateCallbackURL(t *testing.T) {
+	const (
+		invalid  = "callbackUrl must be an absolute http or https URL"
+		internal = "callbackUrl must not point to a loopback, private or link-local address"
+		hostname = "callbackUrl must have a public hostname"
+	)
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{name: "public hostname", url: "https://example.com/hook", want: ""},
+		{name: "public address", url: "http://93.184.216.34:8080/hook", want: ""},
+		{name: "relative", url: "/hook", want: invalid},
+		{name: "other scheme", url: "ftp://example.com/hook", want: invalid},
+		{name: "loopback", url: "http://127.0.0.1/hook", want: internal},
+		{name: "IPv6 loopback", url: "http://[::1]/hook", want: internal},
+		{name: "private", url: "http://192.168.0.10/hook", want: internal},
+		{name: "link-local", url: "http://169.254.169.254/latest/meta-data", want: internal},
+		{name: "IPv4-mapped IPv6", url: "http://[::ffff:10.0.0.1]/hook", want: internal},
+		{name: "dotless host", url: "http://db:5432/hook", want: hostname},
+		{name: "localhost", url: "http://localhost/hook", want: hostname},
+		{name: ".localhost", url: "http://api.localhost/hook", want: hostname},
+		{name: "too long", url: "https://example.com/" + strings.Repeat("a", maxCallbackURLLength), want: "callbackUrl must be at most 2048 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validateCallbackURL(tt.url); got != tt.want {
+				t.Errorf("validateCallbackURL(%q) = %q, want %q", tt.url, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListCalculationsRejectsUnknownStatus(t *testing.T) {
+	status := api.CalculationStatus("done")
+
+	// The store is never reached.
+	s := NewService(nil, nil, nil)
+	resp, err := s.ListCalculations(context.Background(), api.ListCalculationsRequestObject{
+		Params: api.ListCalculationsParams{Status: &status},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bad, ok := resp.(api.ListCalculations400JSONResponse)
+	if !ok {
+		t.Fatalf("got %T, want a 400 response", resp)
+	}
+	if bad.Code != api.CodeInvalidRequest {
+		t.Errorf("code is %q, want %q", bad.Code, api.CodeInvalidRequest)
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	id := uuid.MustParse("0b5f2c1e-8d7a-4c3b-9e6f-1a2b3c4d5e6f")
+
+	gotCreated, gotID, err := decodeCursor(encodeCursor(created, id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gotCreated.Equal(created) {
+		t.Errorf("created is %v, want %v", gotCreated, created)
+	}
+	if gotID != id {
+		t.Errorf("id is %v, want %v", gotID, id)
+	}
+}
+
+func TestDecodeCursorRejectsMalformed(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "empty", cursor: ""},
+		{name: "not base64", cursor: "not a cursor!"},
+		{name: "no separator", cursor: encode("2024-03-01T12:30:45Z")},
+		{name: "bad time", cursor: encode("yesterday,0b5f2c1e-8d7a-4c3b-9e6f-1a2b3c4d5e6f")},
+		{name: "bad id", cursor: encode("2024-03-01T12:30:45Z,42")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) succeeded, want an error", tt.cursor)
+			}
+		})
+	}
+}
+
+func TestParseWait(t *testing.T) {
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name    string
+		params  api.CreateCalculationParams
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "none", params: api.CreateCalculationParams{}, want: 0},
+		{name: "query", params: api.CreateCalculationParams{Wait: str("5s")}, want: 5 * time.Second},
+		{name: "query at the cap", params: api.CreateCalculationParams{Wait: str("30s")}, want: maxWait},
+		{name: "query over the cap", params: api.CreateCalculationParams{Wait: str("31s")}, wantErr: true},
+		{name: "query negative", params: api.CreateCalculationParams{Wait: str("-1s")}, wantErr: true},
+		{name: "query invalid", params: api.CreateCalculationParams{Wait: str("soon")}, wantErr: true},
+		{name: "query without a unit", params: api.CreateCalculationParams{Wait: str("5")}, wantErr: true},
+		{name: "query over Prefer", params: api.CreateCalculationParams{Wait: str("2s"), Prefer: str("wait=10")}, want: 2 * time.Second},
+		{name: "invalid query over Prefer", params: api.CreateCalculationParams{Wait: str("soon"), Prefer: str("wait=10")}, wantErr: true},
+		{name: "Prefer", params: api.CreateCalculationParams{Prefer: str("wait=10")}, want: 10 * time.Second},
+		{name: "Prefer capped", params: api.CreateCalculationParams{Prefer: str("wait=600")}, want: maxWait},
+		{name: "Prefer quoted", params: api.CreateCalculationParams{Prefer: str(`wait="7"`)}, want: 7 * time.Second},
+		{name: "Prefer with parameters", params: api.CreateCalculationParams{Prefer: str("wait=3; foo=bar")}, want: 3 * time.Second},
+		{name: "Prefer among others", params: api.CreateCalculationParams{Prefer: str("respond-async, Wait = 4")}, want: 4 * time.Second},
+		{name: "Prefer invalid", params: api.CreateCalculationParams{Prefer: str("wait=soon")}, want: 0},
+		{name: "Prefer negative", params: api.CreateCalculationParams{Prefer: str("wait=-5")}, want: 0},
+		{name: "Prefer without wait", params: api.CreateCalculationParams{Prefer: str("respond-async")}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWait(tt.params)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWait() error = %v, want error %t", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -14,27 +14,58 @@ import (
 
 const createCalculation = `-- name: CreateCalculation :one
 INSERT INTO calculations (
-  student, expression
+  student, expression, variables, mode, callback_url
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, $5
 )
-RETURNING id
+RETURNING id, student, expression, result, created, completed, status, error, mode, result_decimal, variables, callback_url
 `
 
 type CreateCalculationParams struct {
-	Student    string `json:"student"`
-	Expression string `json:"expression"`
+	Student     string      `json:"student"`
+	Expression  string      `json:"expression"`
+	Variables   []byte      `json:"variables"`
+	Mode        string      `json:"mode"`
+	CallbackUrl pgtype.Text `json:"callback_url"`
 }
 
-func (q *Queries) CreateCalculation(ctx context.Context, arg CreateCalculationParams) (uuid.UUID, error) {
-	row := q.db.QueryRow(ctx, createCalculation, arg.Student, arg.Expression)
-	var id uuid.UUID
-	err := row.Scan(&id)
-	return id, err
+func (q *Queries) CreateCalculation(ctx context.Context, arg CreateCalculationParams) (Calculation, error) {
+	row := q.db.QueryRow(ctx, createCalculation,
+		arg.Student,
+		arg.Expression,
+		arg.Variables,
+		arg.Mode,
+		arg.CallbackUrl,
+	)
+	var i Calculation
+	err := row.Scan(
+		&i.ID,
+		&i.Student,
+		&i.Expression,
+		&i.Result,
+		&i.Created,
+		&i.Completed,
+		&i.Status,
+		&i.Error,
+		&i.Mode,
+		&i.ResultDecimal,
+		&i.Variables,
+		&i.CallbackUrl,
+	)
+	return i, err
+}
+
+type CreateCalculationsParams struct {
+	ID          uuid.UUID   `json:"id"`
+	Student     string      `json:"student"`
+	Expression  string      `json:"expression"`
+	Variables   []byte      `json:"variables"`
+	Mode        string      `json:"mode"`
+	CallbackUrl pgtype.Text `json:"callback_url"`
 }
 
 const getCalculation = `-- name: GetCalculation :one
-SELECT id, student, expression, result, created, completed FROM calculations
+SELECT id, student, expression, result, created, completed, status, error, mode, result_decimal, variables, callback_url FROM calculations
 WHERE id = $1
 `
 
@@ -48,28 +79,113 @@ func (q *Queries) GetCalculation(ctx context.Context, id uuid.UUID) (Calculation
 		&i.Result,
 		&i.Created,
 		&i.Completed,
+		&i.Status,
+		&i.Error,
+		&i.Mode,
+		&i.ResultDecimal,
+		&i.Variables,
+		&i.CallbackUrl,
 	)
 	return i, err
 }
 
+const listCalculations = `-- name: ListCalculations :many
+SELECT id, student, expression, result, created, completed, status, error, mode, result_decimal, variables, callback_url FROM calculations
+WHERE
+  ($1::varchar IS NULL OR student = $1) AND
+  ($2::varchar IS NULL OR status = $2) AND
+  ($3::timestamptz IS NULL OR created >= $3) AND
+  ($4::timestamptz IS NULL OR created < $4) AND
+  ($5::timestamptz IS NULL OR (created, id) < ($5, $6::uuid))
+ORDER BY created DESC, id DESC
+LIMIT $7
+`
+
+type ListCalculationsParams struct {
+	Student       pgtype.Text        `json:"student"`
+	Status        pgtype.Text        `json:"status"`
+	CreatedAfter  pgtype.Timestamptz `json:"created_after"`
+	CreatedBefore pgtype.Timestamptz `json:"created_before"`
+	CursorCreated pgtype.Timestamptz `json:"cursor_created"`
+	CursorID      pgtype.UUID        `json:"cursor_id"`
+	Limit         int32              `json:"limit"`
+}
+
+func (q *Queries) ListCalculations(ctx context.Context, arg ListCalculationsParams) ([]Calculation, error) {
+	rows, err := q.db.Query(ctx, listCalculations,
+		arg.Student,
+		arg.Status,
+		arg.CreatedAfter,
+		arg.CreatedBefore,
+		arg.CursorCreated,
+		arg.CursorID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Calculation{}
+	for rows.Next() {
+		var i Calculation
+		if err := rows.Scan(
+			&i.ID,
+			&i.Student,
+			&i.Expression,
+			&i.Result,
+			&i.Created,
+			&i.Completed,
+			&i.Status,
+			&i.Error,
+			&i.Mode,
+			&i.ResultDecimal,
+			&i.Variables,
+			&i.CallbackUrl,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const updateCalculation = `-- name: UpdateCalculation :one
 UPDATE calculations
 SET
-  result = $1,
-  completed = $2
+  status = $1,
+  result = $2,
+  result_decimal = $3,
+  error = $4,
+  completed = $5
 WHERE
-  id = $3
-RETURNING id, student, expression, result, created, completed
+  id = $6
+  AND status = 'pending'
+RETURNING id, student, expression, result, created, completed, status, error, mode, result_decimal, variables, callback_url
 `
 
 type UpdateCalculationParams struct {
-	Result    pgtype.Float8      `json:"result"`
-	Completed pgtype.Timestamptz `json:"completed"`
-	ID        uuid.UUID          `json:"id"`
+	Status        string             `json:"status"`
+	Result        pgtype.Float8      `json:"result"`
+	ResultDecimal pgtype.Text        `json:"result_decimal"`
+	Error         pgtype.Text        `json:"error"`
+	Completed     pgtype.Timestamptz `json:"completed"`
+	ID            uuid.UUID          `json:"id"`
 }
 
+// Only pending calculations are updated, so a redelivered result can't
+// overwrite the first one.
 func (q *Queries) UpdateCalculation(ctx context.Context, arg UpdateCalculationParams) (Calculation, error) {
-	row := q.db.QueryRow(ctx, updateCalculation, arg.Result, arg.Completed, arg.ID)
+	row := q.db.QueryRow(ctx, updateCalculation,
+		arg.Status,
+		arg.Result,
+		arg.ResultDecimal,
+		arg.Error,
+		arg.Completed,
+		arg.ID,
+	)
 	var i Calculation
 	err := row.Scan(
 		&i.ID,
@@ -78,6 +194,12 @@ func (q *Queries) UpdateCalculation(ctx context.Context, arg UpdateCalculationPa
 		&i.Result,
 		&i.Created,
 		&i.Completed,
+		&i.Status,
+		&i.Error,
+		&i.Mode,
+		&i.ResultDecimal,
+		&i.Variables,
+		&i.CallbackUrl,
 	)
 	return i, err
 }
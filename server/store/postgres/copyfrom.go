@@ -0,0 +1,80 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.23.0
+// source: copyfrom.go
+
+package postgres
+
+import (
+	"context"
+)
+
+// iteratorForCreateCalculations implements pgx.CopyFromSource.
+type iteratorForCreateCalculations struct {
+	rows                 []CreateCalculationsParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCreateCalculations) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCreateCalculations) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].ID,
+		r.rows[0].Student,
+		r.rows[0].Expression,
+		r.rows[0].Variables,
+		r.rows[0].Mode,
+		r.rows[0].CallbackUrl,
+	}, nil
+}
+
+func (r iteratorForCreateCalculations) Err() error {
+	return nil
+}
+
+func (q *Queries) CreateCalculations(ctx context.Context, arg []CreateCalculationsParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"calculations"}, []string{"id", "student", "expression", "variables", "mode", "callback_url"}, &iteratorForCreateCalculations{rows: arg})
+}
+
+// iteratorForCreateOutboxMessages implements pgx.CopyFromSource.
+type iteratorForCreateOutboxMessages struct {
+	rows                 []CreateOutboxMessagesParams
+	skippedFirstNextCall bool
+}
+
+func (r *iteratorForCreateOutboxMessages) Next() bool {
+	if len(r.rows) == 0 {
+		return false
+	}
+	if !r.skippedFirstNextCall {
+		r.skippedFirstNextCall = true
+		return true
+	}
+	r.rows = r.rows[1:]
+	return len(r.rows) > 0
+}
+
+func (r iteratorForCreateOutboxMessages) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.rows[0].Payload,
+		r.rows[0].TraceContext,
+	}, nil
+}
+
+func (r iteratorForCreateOutboxMessages) Err() error {
+	return nil
+}
+
+func (q *Queries) CreateOutboxMessages(ctx context.Context, arg []CreateOutboxMessagesParams) (int64, error) {
+	return q.db.CopyFrom(ctx, []string{"outbox"}, []string{"payload", "trace_context"}, &iteratorForCreateOutboxMessages{rows: arg})
+}
@@ -0,0 +1,99 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.23.0
+// source: idempotency_keys.sql
+
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createIdempotencyKey = `-- name: CreateIdempotencyKey :one
+INSERT INTO idempotency_keys (
+  key, calculation_id, status_code
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  calculation_id = EXCLUDED.calculation_id,
+  status_code = EXCLUDED.status_code,
+  created = NOW()
+WHERE
+  idempotency_keys.created < $4
+RETURNING key, calculation_id, status_code, created
+`
+
+type CreateIdempotencyKeyParams struct {
+	Key           string    `json:"key"`
+	CalculationID uuid.UUID `json:"calculation_id"`
+	StatusCode    int32     `json:"status_code"`
+	RetainedSince time.Time `json:"retained_since"`
+}
+
+// Claims key for a calculation, taking over a key whose retention window has
+// passed. Returns no rows when the key is still held by another calculation.
+func (q *Queries) CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, createIdempotencyKey,
+		arg.Key,
+		arg.CalculationID,
+		arg.StatusCode,
+		arg.RetainedSince,
+	)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.CalculationID,
+		&i.StatusCode,
+		&i.Created,
+	)
+	return i, err
+}
+
+const getIdempotencyKey = `-- name: GetIdempotencyKey :one
+SELECT key, calculation_id, status_code, created FROM idempotency_keys
+WHERE
+  key = $1
+  AND created >= $2
+`
+
+type GetIdempotencyKeyParams struct {
+	Key           string    `json:"key"`
+	RetainedSince time.Time `json:"retained_since"`
+}
+
+func (q *Queries) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	row := q.db.QueryRow(ctx, getIdempotencyKey, arg.Key, arg.RetainedSince)
+	var i IdempotencyKey
+	err := row.Scan(
+		&i.Key,
+		&i.CalculationID,
+		&i.StatusCode,
+		&i.Created,
+	)
+	return i, err
+}
+
+const updateIdempotencyKeyStatusCode = `-- name: UpdateIdempotencyKeyStatusCode :exec
+UPDATE idempotency_keys
+SET
+  status_code = $1
+WHERE
+  key = $2
+  AND calculation_id = $3
+`
+
+type UpdateIdempotencyKeyStatusCodeParams struct {
+	StatusCode    int32     `json:"status_code"`
+	Key           string    `json:"key"`
+	CalculationID uuid.UUID `json:"calculation_id"`
+}
+
+func (q *Queries) UpdateIdempotencyKeyStatusCode(ctx context.Context, arg UpdateIdempotencyKeyStatusCodeParams) error {
+	_, err := q.db.Exec(ctx, updateIdempotencyKeyStatusCode, arg.StatusCode, arg.Key, arg.CalculationID)
+	return err
+}
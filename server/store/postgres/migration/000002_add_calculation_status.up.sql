@@ -0,0 +1,6 @@
+ALTER TABLE calculations
+  ADD COLUMN status VARCHAR NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'completed', 'failed')),
+  ADD COLUMN error VARCHAR;
+
+UPDATE calculations SET status = 'completed' WHERE completed IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS calculations_student_created_id_idx;
+
+DROP INDEX IF EXISTS calculations_created_id_idx;
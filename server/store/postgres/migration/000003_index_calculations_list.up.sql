@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS calculations_created_id_idx ON calculations (created DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS calculations_student_created_id_idx ON calculations (student, created DESC, id DESC);
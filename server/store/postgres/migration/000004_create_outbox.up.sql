@@ -0,0 +1,10 @@
+CREATE TABLE outbox (
+  id BIGSERIAL,
+  payload JSONB NOT NULL,
+  trace_context JSONB NOT NULL DEFAULT '{}',
+  created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  sent TIMESTAMPTZ,
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE sent IS NULL;
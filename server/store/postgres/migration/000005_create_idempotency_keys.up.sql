@@ -0,0 +1,7 @@
+CREATE TABLE idempotency_keys (
+  key VARCHAR,
+  calculation_id uuid NOT NULL REFERENCES calculations (id) ON DELETE CASCADE,
+  status_code INTEGER NOT NULL,
+  created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (key)
+);
@@ -0,0 +1,3 @@
+ALTER TABLE calculations
+  DROP COLUMN IF EXISTS result_decimal,
+  DROP COLUMN IF EXISTS mode;
@@ -0,0 +1,4 @@
+ALTER TABLE calculations
+  ADD COLUMN mode VARCHAR NOT NULL DEFAULT 'float'
+    CHECK (mode IN ('float', 'decimal')),
+  ADD COLUMN result_decimal VARCHAR;
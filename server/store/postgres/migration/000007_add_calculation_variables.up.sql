@@ -0,0 +1,2 @@
+ALTER TABLE calculations
+  ADD COLUMN variables JSONB;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS webhook_deliveries;
+
+ALTER TABLE calculations
+  DROP COLUMN IF EXISTS callback_url;
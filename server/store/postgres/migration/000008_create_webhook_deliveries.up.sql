@@ -0,0 +1,15 @@
+ALTER TABLE calculations
+  ADD COLUMN callback_url VARCHAR;
+
+CREATE TABLE webhook_deliveries (
+  id BIGSERIAL,
+  calculation_id uuid NOT NULL REFERENCES calculations (id) ON DELETE CASCADE,
+  url VARCHAR NOT NULL,
+  attempt INTEGER NOT NULL,
+  status_code INTEGER,
+  error VARCHAR,
+  created TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  PRIMARY KEY (id)
+);
+
+CREATE INDEX webhook_deliveries_calculation_id_idx ON webhook_deliveries (calculation_id);
@@ -0,0 +1,9 @@
+DROP INDEX IF EXISTS outbox_sent_idx;
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE sent IS NULL;
+
+ALTER TABLE outbox
+  DROP COLUMN IF EXISTS failed,
+  DROP COLUMN IF EXISTS last_error,
+  DROP COLUMN IF EXISTS next_attempt,
+  DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1,9 @@
+ALTER TABLE outbox
+  ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+  ADD COLUMN next_attempt TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  ADD COLUMN last_error VARCHAR,
+  ADD COLUMN failed TIMESTAMPTZ;
+
+DROP INDEX IF EXISTS outbox_pending_idx;
+CREATE INDEX outbox_pending_idx ON outbox (id) WHERE sent IS NULL AND failed IS NULL;
+CREATE INDEX outbox_sent_idx ON outbox (sent) WHERE sent IS NOT NULL;
@@ -12,10 +12,45 @@ import (
 )
 
 type Calculation struct {
-	ID         uuid.UUID          `json:"id"`
-	Student    string             `json:"student"`
-	Expression string             `json:"expression"`
-	Result     pgtype.Float8      `json:"result"`
-	Created    time.Time          `json:"created"`
-	Completed  pgtype.Timestamptz `json:"completed"`
+	ID            uuid.UUID          `json:"id"`
+	Student       string             `json:"student"`
+	Expression    string             `json:"expression"`
+	Result        pgtype.Float8      `json:"result"`
+	Created       time.Time          `json:"created"`
+	Completed     pgtype.Timestamptz `json:"completed"`
+	Status        string             `json:"status"`
+	Error         pgtype.Text        `json:"error"`
+	Mode          string             `json:"mode"`
+	ResultDecimal pgtype.Text        `json:"result_decimal"`
+	Variables     []byte             `json:"variables"`
+	CallbackUrl   pgtype.Text        `json:"callback_url"`
+}
+
+type IdempotencyKey struct {
+	Key           string    `json:"key"`
+	CalculationID uuid.UUID `json:"calculation_id"`
+	StatusCode    int32     `json:"status_code"`
+	Created       time.Time `json:"created"`
+}
+
+type Outbox struct {
+	ID           int64              `json:"id"`
+	Payload      []byte             `json:"payload"`
+	TraceContext []byte             `json:"trace_context"`
+	Created      time.Time          `json:"created"`
+	Sent         pgtype.Timestamptz `json:"sent"`
+	Attempts     int32              `json:"attempts"`
+	NextAttempt  time.Time          `json:"next_attempt"`
+	LastError    pgtype.Text        `json:"last_error"`
+	Failed       pgtype.Timestamptz `json:"failed"`
+}
+
+type WebhookDelivery struct {
+	ID            int64       `json:"id"`
+	CalculationID uuid.UUID   `json:"calculation_id"`
+	Url           string      `json:"url"`
+	Attempt       int32       `json:"attempt"`
+	StatusCode    pgtype.Int4 `json:"status_code"`
+	Error         pgtype.Text `json:"error"`
+	Created       time.Time   `json:"created"`
 }
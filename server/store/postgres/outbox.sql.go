@@ -0,0 +1,156 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.23.0
+// source: outbox.sql
+
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createOutboxMessage = `-- name: CreateOutboxMessage :exec
+INSERT INTO outbox (
+  payload, trace_context
+) VALUES (
+  $1, $2
+)
+`
+
+type CreateOutboxMessageParams struct {
+	Payload      []byte `json:"payload"`
+	TraceContext []byte `json:"trace_context"`
+}
+
+func (q *Queries) CreateOutboxMessage(ctx context.Context, arg CreateOutboxMessageParams) error {
+	_, err := q.db.Exec(ctx, createOutboxMessage, arg.Payload, arg.TraceContext)
+	return err
+}
+
+type CreateOutboxMessagesParams struct {
+	Payload      []byte `json:"payload"`
+	TraceContext []byte `json:"trace_context"`
+}
+
+const deleteSentOutboxMessages = `-- name: DeleteSentOutboxMessages :execrows
+DELETE FROM outbox
+WHERE id IN (
+  SELECT o.id FROM outbox o
+  WHERE o.sent < $2
+  ORDER BY o.sent
+  LIMIT $1
+)
+`
+
+type DeleteSentOutboxMessagesParams struct {
+	Limit      int32              `json:"limit"`
+	SentBefore pgtype.Timestamptz `json:"sent_before"`
+}
+
+func (q *Queries) DeleteSentOutboxMessages(ctx context.Context, arg DeleteSentOutboxMessagesParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteSentOutboxMessages, arg.Limit, arg.SentBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const listPendingOutboxMessages = `-- name: ListPendingOutboxMessages :many
+SELECT id, payload, trace_context, created, sent, attempts, next_attempt, last_error, failed FROM outbox
+WHERE
+  sent IS NULL
+  AND failed IS NULL
+  AND next_attempt <= NOW()
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+func (q *Queries) ListPendingOutboxMessages(ctx context.Context, limit int32) ([]Outbox, error) {
+	rows, err := q.db.Query(ctx, listPendingOutboxMessages, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Outbox{}
+	for rows.Next() {
+		var i Outbox
+		if err := rows.Scan(
+			&i.ID,
+			&i.Payload,
+			&i.TraceContext,
+			&i.Created,
+			&i.Sent,
+			&i.Attempts,
+			&i.NextAttempt,
+			&i.LastError,
+			&i.Failed,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markOutboxMessageSent = `-- name: MarkOutboxMessageSent :exec
+UPDATE outbox
+SET
+  sent = NOW()
+WHERE
+  id = $1
+`
+
+func (q *Queries) MarkOutboxMessageSent(ctx context.Context, id int64) error {
+	_, err := q.db.Exec(ctx, markOutboxMessageSent, id)
+	return err
+}
+
+const parkOutboxMessage = `-- name: ParkOutboxMessage :exec
+UPDATE outbox
+SET
+  attempts = attempts + 1,
+  last_error = $2,
+  failed = NOW()
+WHERE
+  id = $1
+`
+
+type ParkOutboxMessageParams struct {
+	ID        int64       `json:"id"`
+	LastError pgtype.Text `json:"last_error"`
+}
+
+// Records a failed attempt and stops relaying the message.
+func (q *Queries) ParkOutboxMessage(ctx context.Context, arg ParkOutboxMessageParams) error {
+	_, err := q.db.Exec(ctx, parkOutboxMessage, arg.ID, arg.LastError)
+	return err
+}
+
+const retryOutboxMessage = `-- name: RetryOutboxMessage :exec
+UPDATE outbox
+SET
+  attempts = attempts + 1,
+  last_error = $2,
+  next_attempt = $3
+WHERE
+  id = $1
+`
+
+type RetryOutboxMessageParams struct {
+	ID          int64       `json:"id"`
+	LastError   pgtype.Text `json:"last_error"`
+	NextAttempt time.Time   `json:"next_attempt"`
+}
+
+// Records a failed attempt and holds the message back until next_attempt.
+func (q *Queries) RetryOutboxMessage(ctx context.Context, arg RetryOutboxMessageParams) error {
+	_, err := q.db.Exec(ctx, retryOutboxMessage, arg.ID, arg.LastError, arg.NextAttempt)
+	return err
+}
@@ -4,13 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"log"
+	"time"
 
+	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
 	"github.com/exaring/otelpgx"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
 )
 
 type Config struct {
@@ -18,35 +21,110 @@ type Config struct {
 	User         string
 	Password     string
 	DatabaseName string
+
+	// Zero values keep the pgxpool defaults.
+	MaxConns        int32
+	MinConns        int32
+	MaxConnLifetime time.Duration
+	MaxConnIdleTime time.Duration
 }
 
-func ConnectAndMigrate(ctx context.Context, cfg Config) (*pgx.Conn, error) {
+func ConnectAndMigrate(ctx context.Context, cfg Config) (*pgxpool.Pool, error) {
 	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", cfg.User, cfg.Password, cfg.Host, cfg.DatabaseName)
 
 	if err := runMigrations(ctx, dsn); err != nil {
 		return nil, err
 	}
 
-	connConfig, err := pgx.ParseConfig(dsn)
+	poolConfig, err := pgxpool.ParseConfig(dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	connConfig.Tracer = otelpgx.NewTracer(
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+
+	poolConfig.ConnConfig.Tracer = otelpgx.NewTracer(
 		otelpgx.WithTrimSQLInSpanName(),
 		otelpgx.WithIncludeQueryParameters(),
 	)
 
-	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := conn.Ping(ctx); err != nil {
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
 		return nil, err
 	}
 
-	return conn, nil
+	if err := recordPoolStats(pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// recordPoolStats exports pool statistics as asynchronous OTel instruments.
+func recordPoolStats(pool *pgxpool.Pool) error {
+	meter := otelcommon.Meter()
+	poolName := attribute.String("pool.name", pool.Config().ConnConfig.Database)
+
+	usage, err := meter.Int64ObservableUpDownCounter("db.client.connections.usage",
+		metric.WithDescription("The number of connections that are currently in the state described by the state attribute."),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+	maxConns, err := meter.Int64ObservableUpDownCounter("db.client.connections.max",
+		metric.WithDescription("The maximum number of open connections allowed."),
+		metric.WithUnit("{connection}"))
+	if err != nil {
+		return err
+	}
+	acquires, err := meter.Int64ObservableCounter("db.client.connections.acquires",
+		metric.WithDescription("The number of successful connection acquires from the pool."),
+		metric.WithUnit("{acquire}"))
+	if err != nil {
+		return err
+	}
+	canceled, err := meter.Int64ObservableCounter("db.client.connections.acquires.canceled",
+		metric.WithDescription("The number of connection acquires canceled by their context, such as on a request timeout or a client disconnect."),
+		metric.WithUnit("{acquire}"))
+	if err != nil {
+		return err
+	}
+	waitTime, err := meter.Float64ObservableCounter("db.client.connections.wait_time",
+		metric.WithDescription("The total time spent acquiring connections from the pool."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stat := pool.Stat()
+		o.ObserveInt64(usage, int64(stat.IdleConns()), metric.WithAttributes(poolName, attribute.String("state", "idle")))
+		o.ObserveInt64(usage, int64(stat.AcquiredConns()), metric.WithAttributes(poolName, attribute.String("state", "used")))
+		o.ObserveInt64(maxConns, int64(stat.MaxConns()), metric.WithAttributes(poolName))
+		o.ObserveInt64(acquires, stat.AcquireCount(), metric.WithAttributes(poolName))
+		o.ObserveInt64(canceled, stat.CanceledAcquireCount(), metric.WithAttributes(poolName))
+		o.ObserveFloat64(waitTime, stat.AcquireDuration().Seconds(), metric.WithAttributes(poolName))
+		return nil
+	}, usage, maxConns, acquires, canceled, waitTime)
+
+	return err
 }
 
 func runMigrations(ctx context.Context, dsn string) error {
@@ -58,7 +136,7 @@ func runMigrations(ctx context.Context, dsn string) error {
 
 	driver, err := postgres.WithInstance(db, &postgres.Config{})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	m, err := migrate.NewWithDatabaseInstance("file://migration", "postgres", driver)
@@ -11,9 +11,28 @@ import (
 )
 
 type Querier interface {
-	CreateCalculation(ctx context.Context, arg CreateCalculationParams) (uuid.UUID, error)
+	CreateCalculation(ctx context.Context, arg CreateCalculationParams) (Calculation, error)
+	CreateCalculations(ctx context.Context, arg []CreateCalculationsParams) (int64, error)
+	// Claims key for a calculation, taking over a key whose retention window has
+	// passed. Returns no rows when the key is still held by another calculation.
+	CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error)
+	CreateOutboxMessage(ctx context.Context, arg CreateOutboxMessageParams) error
+	CreateOutboxMessages(ctx context.Context, arg []CreateOutboxMessagesParams) (int64, error)
+	CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error
+	DeleteSentOutboxMessages(ctx context.Context, arg DeleteSentOutboxMessagesParams) (int64, error)
 	GetCalculation(ctx context.Context, id uuid.UUID) (Calculation, error)
+	GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error)
+	ListCalculations(ctx context.Context, arg ListCalculationsParams) ([]Calculation, error)
+	ListPendingOutboxMessages(ctx context.Context, limit int32) ([]Outbox, error)
+	MarkOutboxMessageSent(ctx context.Context, id int64) error
+	// Records a failed attempt and stops relaying the message.
+	ParkOutboxMessage(ctx context.Context, arg ParkOutboxMessageParams) error
+	// Records a failed attempt and holds the message back until next_attempt.
+	RetryOutboxMessage(ctx context.Context, arg RetryOutboxMessageParams) error
+	// Only pending calculations are updated, so a redelivered result can't
+	// overwrite the first one.
 	UpdateCalculation(ctx context.Context, arg UpdateCalculationParams) (Calculation, error)
+	UpdateIdempotencyKeyStatusCode(ctx context.Context, arg UpdateIdempotencyKeyStatusCodeParams) error
 }
 
 var _ Querier = (*Queries)(nil)
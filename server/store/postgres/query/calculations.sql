@@ -1,20 +1,43 @@
 -- name: CreateCalculation :one
 INSERT INTO calculations (
-  student, expression
+  student, expression, variables, mode, callback_url
 ) VALUES (
-  $1, $2
+  $1, $2, $3, $4, $5
 )
-RETURNING id;
+RETURNING *;
 
 -- name: GetCalculation :one
 SELECT * FROM calculations
 WHERE id = $1;
 
 -- name: UpdateCalculation :one
+-- Only pending calculations are updated, so a redelivered result can't
+-- overwrite the first one.
 UPDATE calculations
 SET
-  result = $1,
-  completed = $2
+  status = $1,
+  result = $2,
+  result_decimal = $3,
+  error = $4,
+  completed = $5
 WHERE
-  id = $3
-RETURNING *;
\ No newline at end of file
+  id = $6
+  AND status = 'pending'
+RETURNING *;
+
+-- name: ListCalculations :many
+SELECT * FROM calculations
+WHERE
+  (sqlc.narg('student')::varchar IS NULL OR student = sqlc.narg('student')) AND
+  (sqlc.narg('status')::varchar IS NULL OR status = sqlc.narg('status')) AND
+  (sqlc.narg('created_after')::timestamptz IS NULL OR created >= sqlc.narg('created_after')) AND
+  (sqlc.narg('created_before')::timestamptz IS NULL OR created < sqlc.narg('created_before')) AND
+  (sqlc.narg('cursor_created')::timestamptz IS NULL OR (created, id) < (sqlc.narg('cursor_created'), sqlc.narg('cursor_id')::uuid))
+ORDER BY created DESC, id DESC
+LIMIT sqlc.arg('limit');
+-- name: CreateCalculations :copyfrom
+INSERT INTO calculations (
+  id, student, expression, variables, mode, callback_url
+) VALUES (
+  $1, $2, $3, $4, $5, $6
+);
@@ -0,0 +1,30 @@
+-- name: GetIdempotencyKey :one
+SELECT * FROM idempotency_keys
+WHERE
+  key = $1
+  AND created >= sqlc.arg('retained_since');
+
+-- name: CreateIdempotencyKey :one
+-- Claims key for a calculation, taking over a key whose retention window has
+-- passed. Returns no rows when the key is still held by another calculation.
+INSERT INTO idempotency_keys (
+  key, calculation_id, status_code
+) VALUES (
+  $1, $2, $3
+)
+ON CONFLICT (key) DO UPDATE
+SET
+  calculation_id = EXCLUDED.calculation_id,
+  status_code = EXCLUDED.status_code,
+  created = NOW()
+WHERE
+  idempotency_keys.created < sqlc.arg('retained_since')
+RETURNING *;
+
+-- name: UpdateIdempotencyKeyStatusCode :exec
+UPDATE idempotency_keys
+SET
+  status_code = $1
+WHERE
+  key = $2
+  AND calculation_id = $3;
@@ -0,0 +1,59 @@
+-- name: CreateOutboxMessage :exec
+INSERT INTO outbox (
+  payload, trace_context
+) VALUES (
+  $1, $2
+);
+
+-- name: ListPendingOutboxMessages :many
+SELECT * FROM outbox
+WHERE
+  sent IS NULL
+  AND failed IS NULL
+  AND next_attempt <= NOW()
+ORDER BY id
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
+
+-- name: MarkOutboxMessageSent :exec
+UPDATE outbox
+SET
+  sent = NOW()
+WHERE
+  id = $1;
+
+-- name: RetryOutboxMessage :exec
+-- Records a failed attempt and holds the message back until next_attempt.
+UPDATE outbox
+SET
+  attempts = attempts + 1,
+  last_error = $2,
+  next_attempt = $3
+WHERE
+  id = $1;
+
+-- name: ParkOutboxMessage :exec
+-- Records a failed attempt and stops relaying the message.
+UPDATE outbox
+SET
+  attempts = attempts + 1,
+  last_error = $2,
+  failed = NOW()
+WHERE
+  id = $1;
+
+-- name: DeleteSentOutboxMessages :execrows
+DELETE FROM outbox
+WHERE id IN (
+  SELECT o.id FROM outbox o
+  WHERE o.sent < sqlc.arg('sent_before')
+  ORDER BY o.sent
+  LIMIT $1
+);
+
+-- name: CreateOutboxMessages :copyfrom
+INSERT INTO outbox (
+  payload, trace_context
+) VALUES (
+  $1, $2
+);
@@ -0,0 +1,6 @@
+-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (
+  calculation_id, url, attempt, status_code, error
+) VALUES (
+  $1, $2, $3, $4, $5
+);
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrNotFound is returned when a query matches no row.
+	ErrNotFound = errors.New("not found")
+	// ErrIdempotencyKeyInUse is returned when an idempotency key is still
+	// held by another calculation.
+	ErrIdempotencyKeyInUse = errors.New("idempotency key in use")
+	// ErrAlreadyCompleted is returned when updating a calculation that is
+	// no longer pending.
+	ErrAlreadyCompleted = errors.New("calculation already completed")
+)
+
+// DB is a DBTX that can start transactions, such as a *pgxpool.Pool.
+type DB interface {
+	DBTX
+	Begin(context.Context) (pgx.Tx, error)
+}
+
+// Store wraps the generated Queries and translates driver errors into the
+// package's typed errors.
+type Store struct {
+	*Queries
+	db DB
+}
+
+func NewStore(db DB) *Store {
+	return &Store{Queries: New(db), db: db}
+}
+
+// ExecTx runs fn with a Store bound to a transaction, committing if fn returns
+// nil and rolling back otherwise. Nested calls use savepoints.
+func (s *Store) ExecTx(ctx context.Context, fn func(*Store) error) error {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&Store{Queries: s.Queries.WithTx(tx), db: tx}); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+func (s *Store) GetCalculation(ctx context.Context, id uuid.UUID) (Calculation, error) {
+	calc, err := s.Queries.GetCalculation(ctx, id)
+	return calc, translate(err)
+}
+
+func (s *Store) UpdateCalculation(ctx context.Context, arg UpdateCalculationParams) (Calculation, error) {
+	calc, err := s.Queries.UpdateCalculation(ctx, arg)
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return calc, err
+	}
+
+	// Tell a missing calculation from one that already has a result.
+	calc, err = s.GetCalculation(ctx, arg.ID)
+	if err != nil {
+		return calc, err
+	}
+	return calc, ErrAlreadyCompleted
+}
+
+func (s *Store) GetIdempotencyKey(ctx context.Context, arg GetIdempotencyKeyParams) (IdempotencyKey, error) {
+	key, err := s.Queries.GetIdempotencyKey(ctx, arg)
+	return key, translate(err)
+}
+
+func (s *Store) CreateIdempotencyKey(ctx context.Context, arg CreateIdempotencyKeyParams) (IdempotencyKey, error) {
+	key, err := s.Queries.CreateIdempotencyKey(ctx, arg)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return key, ErrIdempotencyKeyInUse
+	}
+	return key, err
+}
+
+func translate(err error) error {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
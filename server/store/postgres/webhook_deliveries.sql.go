@@ -0,0 +1,40 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.23.0
+// source: webhook_deliveries.sql
+
+package postgres
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createWebhookDelivery = `-- name: CreateWebhookDelivery :exec
+INSERT INTO webhook_deliveries (
+  calculation_id, url, attempt, status_code, error
+) VALUES (
+  $1, $2, $3, $4, $5
+)
+`
+
+type CreateWebhookDeliveryParams struct {
+	CalculationID uuid.UUID   `json:"calculation_id"`
+	Url           string      `json:"url"`
+	Attempt       int32       `json:"attempt"`
+	StatusCode    pgtype.Int4 `json:"status_code"`
+	Error         pgtype.Text `json:"error"`
+}
+
+func (q *Queries) CreateWebhookDelivery(ctx context.Context, arg CreateWebhookDeliveryParams) error {
+	_, err := q.db.Exec(ctx, createWebhookDelivery,
+		arg.CalculationID,
+		arg.Url,
+		arg.Attempt,
+		arg.StatusCode,
+		arg.Error,
+	)
+	return err
+}
@@ -0,0 +1,244 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	otelcommon "github.com/MukeshGKastala/nola-otel-demo/common/otel"
+	"github.com/MukeshGKastala/nola-otel-demo/server/egress"
+	"github.com/MukeshGKastala/nola-otel-demo/server/store/postgres"
+	"github.com/jackc/pgx/v5/pgtype"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SignatureHeader carries t=<unix time>,v1=<hex HMAC-SHA256 of
+// "<unix time>.<body>">, so receivers can check both origin and freshness.
+const SignatureHeader = "X-Calculator-Signature"
+
+type Store interface {
+	CreateWebhookDelivery(context.Context, postgres.CreateWebhookDeliveryParams) error
+}
+
+type Config struct {
+	// Secret signs each delivery. It is required.
+	Secret string
+	// MaxAttempts defaults to 5.
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failed attempt, doubling
+	// after each further one. Defaults to 1s.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the wait between attempts. Defaults to 1m.
+	MaxBackoff time.Duration
+	// Timeout bounds a single attempt. Defaults to 10s.
+	Timeout time.Duration
+}
+
+// Dispatcher POSTs finished calculations to their callback URL. Retries are
+// kept in memory, so deliveries still pending at shutdown are abandoned;
+// every attempt is recorded in the store.
+type Dispatcher struct {
+	cfg    Config
+	store  Store
+	client *http.Client
+
+	// ctx is cancelled by Close to stop pending retries.
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewDispatcher(cfg Config, store Store) (*Dispatcher, error) {
+	if cfg.Secret == "" {
+		return nil, errors.New("webhook secret is required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Minute
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	// Connections are refused to internal addresses, whatever the
+	// callback URL's hostname resolves to when it is dialled. A proxy
+	// would be dialled in place of the callback, so none is used.
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   egress.Control,
+	}).DialContext
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Dispatcher{
+		cfg:   cfg,
+		store: store,
+		// otelhttp injects the trace context, so the receiver's spans
+		// join the calculation's trace.
+		client: &http.Client{
+			Transport: otelhttp.NewTransport(transport),
+			Timeout:   cfg.Timeout,
+		},
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// Publish delivers body, the encoded calc, in the background if calc has a
+// callback URL.
+func (d *Dispatcher) Publish(ctx context.Context, calc postgres.Calculation, body []byte) {
+	if !calc.CallbackUrl.Valid {
+		return
+	}
+
+	// Keep the trace of the result but not its lifetime.
+	ctx = trace.ContextWithSpanContext(d.ctx, trace.SpanContextFromContext(ctx))
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		d.deliver(ctx, calc, body)
+	}()
+}
+
+// Close cancels pending deliveries and waits, until ctx is done, for them to
+// record their last attempt.
+func (d *Dispatcher) Close(ctx context.Context) {
+	d.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		d.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, calc postgres.Calculation, body []byte) {
+	url := calc.CallbackUrl.String
+
+	opts := []trace.SpanStartOption{
+		trace.WithAttributes(
+			attribute.String("calculation.id", calc.ID.String()),
+			attribute.String("url.full", url),
+		),
+	}
+	ctx, span := otelcommon.Tracer().Start(ctx, "webhook deliver", opts...)
+	defer span.End()
+
+	backoff := d.cfg.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		statusCode, err := d.post(ctx, url, body)
+
+		delivery := postgres.CreateWebhookDeliveryParams{
+			CalculationID: calc.ID,
+			Url:           url,
+			Attempt:       int32(attempt),
+		}
+		if statusCode != 0 {
+			delivery.StatusCode = pgtype.Int4{Int32: int32(statusCode), Valid: true}
+		}
+		if err != nil {
+			delivery.Error = pgtype.Text{String: err.Error(), Valid: true}
+		}
+		if err := d.store.CreateWebhookDelivery(context.WithoutCancel(ctx), delivery); err != nil {
+			slog.ErrorContext(ctx, "Unable to record webhook delivery", "error", err)
+		}
+
+		span.SetAttributes(attribute.Int("webhook.attempts", attempt))
+
+		if err == nil {
+			return
+		}
+
+		if !retryable(statusCode) || attempt == d.cfg.MaxAttempts {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			slog.WarnContext(ctx, "Giving up on webhook delivery", "id", calc.ID, "attempts", attempt, "error", err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			span.SetStatus(codes.Error, "shut down before delivery")
+			return
+		case <-time.After(backoff):
+		}
+
+		backoff = min(backoff*2, d.cfg.MaxBackoff)
+	}
+}
+
+// post makes one delivery attempt. It returns the response status code, if
+// there was a response, and an error unless the status was 2xx.
+func (d *Dispatcher) post(ctx context.Context, url string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, sign(d.cfg.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether an attempt that ended with statusCode, or with
+// no response if it is 0, may succeed when repeated.
+func retryable(statusCode int) bool {
+	switch {
+	case statusCode == 0,
+		statusCode == http.StatusRequestTimeout,
+		statusCode == http.StatusTooManyRequests,
+		statusCode >= 500:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	// Computed independently with:
+	//   printf '1700000000.{"result":3}' | openssl dgst -sha256 -hmac whsec
+	const want = "t=1700000000,v1=f83f922adf7c90a48e01a7c66b7b8cbe2a92ea4365dbfe35a69772ec4ae5c58c"
+
+	got := sign("whsec", time.Unix(1700000000, 0), []byte(`{"result":3}`))
+	if got != want {
+		t.Errorf("sign() = %q, want %q", got, want)
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		statusCode int
+		want       bool
+	}{
+		{statusCode: 0, want: true},
+		{statusCode: http.StatusRequestTimeout, want: true},
+		{statusCode: http.StatusTooManyRequests, want: true},
+		{statusCode: http.StatusInternalServerError, want: true},
+		{statusCode: http.StatusBadGateway, want: true},
+		{statusCode: http.StatusServiceUnavailable, want: true},
+		{statusCode: http.StatusMovedPermanently, want: false},
+		{statusCode: http.StatusBadRequest, want: false},
+		{statusCode: http.StatusUnauthorized, want: false},
+		{statusCode: http.StatusNotFound, want: false},
+		{statusCode: http.StatusGone, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.statusCode), func(t *testing.T) {
+			if got := retryable(tt.statusCode); got != tt.want {
+				t.Errorf("retryable(%d) = %t, want %t", tt.statusCode, got, tt.want)
+			}
+		})
+	}
+}